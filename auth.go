@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Публичные ключи Google для проверки ID-токенов
+const googleJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+var googleIssuers = []string{"accounts.google.com", "https://accounts.google.com"}
+
+type ctxKey int
+
+const (
+	ctxKeyIdentity ctxKey = iota
+	ctxKeyUser
+	ctxKeySession
+)
+
+// Проверенные данные из Google ID-токена
+type GoogleIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+type googleClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+	jwt.RegisteredClaims
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// googleVerifier проверяет подпись, audience и срок действия ID-токенов.
+// Ключи JWKS кешируются и перезапрашиваются при появлении неизвестного kid.
+type googleVerifier struct {
+	jwksURL    string
+	audience   string
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+func newGoogleVerifier(jwksURL, audience string) *googleVerifier {
+	return &googleVerifier{
+		jwksURL:    jwksURL,
+		audience:   audience,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		keys:       map[string]*rsa.PublicKey{},
+	}
+}
+
+func (v *googleVerifier) verify(ctx context.Context, raw string) (*GoogleIdentity, error) {
+	var claims googleClaims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithAudience(v.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	validIssuer := false
+	for _, iss := range googleIssuers {
+		if claims.Issuer == iss {
+			validIssuer = true
+			break
+		}
+	}
+	if !validIssuer {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+
+	return &GoogleIdentity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+		Picture:       claims.Picture,
+	}, nil
+}
+
+func (v *googleVerifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key, ok := v.keys[kid]
+	stale := time.Since(v.fetchedAt) > time.Hour
+	// Неизвестный kid перезапрашиваем не чаще раза в минуту
+	if ok && !stale {
+		return key, nil
+	}
+	if !ok && !stale && time.Since(v.fetchedAt) < time.Minute {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	keys, err := v.fetchKeys(ctx)
+	if err != nil {
+		if ok {
+			return key, nil
+		}
+		return nil, err
+	}
+	v.keys = keys
+	v.fetchedAt = time.Now()
+
+	key, ok = v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+func (v *googleVerifier) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: bad modulus for %q: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: bad exponent for %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+func bearerToken(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	if len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return ""
+}
+
+// Middleware: проверяет токен из заголовка Authorization и кладёт
+// пользователя в контекст запроса. Принимаются собственные access-токены
+// сессий и Google ID-токены. Запросы без токена пропускаются дальше,
+// ограничения на запись накладывают requireIdentity/requireUser.
+func (s *server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		findCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		var lookup func(ctx context.Context) (User, error)
+		if claims, err := s.parseAccessToken(token); err == nil {
+			sessionID, err := primitive.ObjectIDFromHex(claims.SessionID)
+			if err != nil {
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
+			}
+			userID, err := primitive.ObjectIDFromHex(claims.Subject)
+			if err != nil {
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
+			}
+			session, err := s.sessions.GetActiveSession(findCtx, sessionID)
+			if errors.Is(err, errNotFound) {
+				http.Error(w, "Session expired", http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			ctx = context.WithValue(ctx, ctxKeySession, &session)
+			lookup = func(ctx context.Context) (User, error) { return s.users.GetUser(ctx, userID) }
+		} else {
+			identity, err := s.verifier.verify(ctx, token)
+			if err != nil {
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
+			}
+			ctx = context.WithValue(ctx, ctxKeyIdentity, identity)
+			lookup = func(ctx context.Context) (User, error) { return s.users.GetUserByGoogleID(ctx, identity.Subject) }
+		}
+
+		user, err := lookup(findCtx)
+		switch {
+		case err == nil:
+			ctx = context.WithValue(ctx, ctxKeyUser, &user)
+		case !errors.Is(err, errNotFound):
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		case sessionFromContext(ctx) != nil:
+			// Сессия пережила удаление пользователя
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func identityFromContext(ctx context.Context) *GoogleIdentity {
+	identity, _ := ctx.Value(ctxKeyIdentity).(*GoogleIdentity)
+	return identity
+}
+
+func userFromContext(ctx context.Context) *User {
+	user, _ := ctx.Value(ctxKeyUser).(*User)
+	return user
+}
+
+func sessionFromContext(ctx context.Context) *Session {
+	session, _ := ctx.Value(ctxKeySession).(*Session)
+	return session
+}
+
+// Требует валидный Google ID-токен (пользователь может ещё не существовать)
+func requireIdentity(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if identityFromContext(r.Context()) == nil {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Требует зарегистрированного пользователя
+func requireUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if userFromContext(r.Context()) == nil {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+const roleAdmin = "admin"
+
+func isAdmin(user *User) bool {
+	return user != nil && user.Role == roleAdmin
+}
+
+// Проверяет, что вызывающий — владелец документа или администратор.
+// При отказе сам отвечает 403.
+func authorize(w http.ResponseWriter, r *http.Request, owner string) bool {
+	user := userFromContext(r.Context())
+	if user != nil && (user.ID.Hex() == owner || isAdmin(user)) {
+		return true
+	}
+	http.Error(w, "Forbidden", http.StatusForbidden)
+	return false
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGoogleVerifier(t *testing.T) {
+	jwks := newTestJWKS(t)
+	key := jwks.addKey(t, "k1")
+	otherKey := jwks.addKey(t, "k2")
+
+	with := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		claims := googleTestClaims("alice")
+		change(claims)
+		return claims
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"valid", signTestToken(t, key, "k1", googleTestClaims("alice")), true},
+		{"bad signature", signTestToken(t, otherKey, "k1", googleTestClaims("alice")), false},
+		{"wrong aud", signTestToken(t, key, "k1", with(func(c jwt.MapClaims) { c["aud"] = "someone-else" })), false},
+		{"expired", signTestToken(t, key, "k1", with(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() })), false},
+		{"no exp", signTestToken(t, key, "k1", with(func(c jwt.MapClaims) { delete(c, "exp") })), false},
+		{"wrong issuer", signTestToken(t, key, "k1", with(func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" })), false},
+		{"no subject", signTestToken(t, key, "k1", with(func(c jwt.MapClaims) { c["sub"] = "" })), false},
+		{"malformed", "not-a-jwt", false},
+	}
+
+	v := newGoogleVerifier(jwks.URL, testClientID)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := v.verify(context.Background(), tt.token)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("verify: %v", err)
+				}
+				if identity.Subject != "alice" || identity.Email != "alice@example.com" || !identity.EmailVerified {
+					t.Errorf("identity = %+v", identity)
+				}
+				return
+			}
+			if err == nil {
+				t.Errorf("verify succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestGoogleVerifierRefetchesUnknownKid(t *testing.T) {
+	jwks := newTestJWKS(t)
+	k1 := jwks.addKey(t, "k1")
+	v := newGoogleVerifier(jwks.URL, testClientID)
+
+	if _, err := v.verify(context.Background(), signTestToken(t, k1, "k1", googleTestClaims("alice"))); err != nil {
+		t.Fatal(err)
+	}
+	if n := jwks.fetchCount(); n != 1 {
+		t.Fatalf("fetches = %d, want 1", n)
+	}
+
+	// Google опубликовал новый ключ
+	k2 := jwks.addKey(t, "k2")
+	token := signTestToken(t, k2, "k2", googleTestClaims("alice"))
+
+	// Сразу после загрузки неизвестный kid не вызывает новый запрос
+	if _, err := v.verify(context.Background(), token); err == nil {
+		t.Fatal("verify succeeded with unknown kid inside refetch interval")
+	}
+	if n := jwks.fetchCount(); n != 1 {
+		t.Fatalf("fetches = %d, want 1", n)
+	}
+
+	v.mu.Lock()
+	v.fetchedAt = time.Now().Add(-2 * time.Minute)
+	v.mu.Unlock()
+
+	if _, err := v.verify(context.Background(), token); err != nil {
+		t.Fatalf("verify after refetch: %v", err)
+	}
+	if n := jwks.fetchCount(); n != 2 {
+		t.Fatalf("fetches = %d, want 2", n)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	api := newTestAPI(t)
+	_, token := api.register("alice")
+
+	expired := googleTestClaims("alice")
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	badKey := api.jwks.addKey(t, "unused")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   interface{}
+		want   int
+	}{
+		{"anonymous read", "GET", "/api/twitter/posts", "", nil, http.StatusOK},
+		{"anonymous write", "POST", "/api/twitter/posts", "", map[string]string{"text": "hi"}, http.StatusUnauthorized},
+		{"valid token write", "POST", "/api/twitter/posts", token, map[string]string{"text": "hi"}, http.StatusCreated},
+		{"bad signature", "GET", "/api/twitter/posts", signTestToken(t, badKey, "test-key", googleTestClaims("alice")), nil, http.StatusUnauthorized},
+		{"expired token", "GET", "/api/twitter/posts", signTestToken(t, api.key, "test-key", expired), nil, http.StatusUnauthorized},
+		{"unregistered write", "POST", "/api/twitter/posts", api.token("bob"), map[string]string{"text": "hi"}, http.StatusUnauthorized},
+		{"unauthenticated register", "POST", "/api/twitter/users", "", map[string]string{"name": "eve"}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := api.do(tt.method, tt.path, tt.token, tt.body); rec.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthenticateSessionToken(t *testing.T) {
+	api := newTestAPI(t)
+	_, idToken := api.register("alice")
+
+	var tokens tokenPair
+	decodeJSON(t, api.expect(http.StatusCreated, "POST", "/api/twitter/auth/login", idToken, nil), &tokens)
+	api.expect(http.StatusCreated, "POST", "/api/twitter/posts", tokens.AccessToken, map[string]string{"text": "hi"})
+
+	api.expect(http.StatusOK, "POST", "/api/twitter/auth/logout", tokens.AccessToken, nil)
+	api.expect(http.StatusUnauthorized, "POST", "/api/twitter/posts", tokens.AccessToken, map[string]string{"text": "hi"})
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	// Сколько ротаций назад выдан повторно предъявленный токен
+	for _, age := range []int{1, 2, 5} {
+		t.Run(fmt.Sprintf("%d rotations old", age), func(t *testing.T) {
+			api := newTestAPI(t)
+			_, idToken := api.register("alice")
+
+			var tokens tokenPair
+			decodeJSON(t, api.expect(http.StatusCreated, "POST", "/api/twitter/auth/login", idToken, nil), &tokens)
+			issued := []tokenPair{tokens}
+			for i := 0; i < age; i++ {
+				decodeJSON(t, api.expect(http.StatusOK, "POST", "/api/twitter/auth/refresh", "",
+					map[string]string{"refreshToken": tokens.RefreshToken}), &tokens)
+				issued = append(issued, tokens)
+			}
+
+			stale := issued[len(issued)-1-age].RefreshToken
+			api.expect(http.StatusUnauthorized, "POST", "/api/twitter/auth/refresh", "", map[string]string{"refreshToken": stale})
+
+			// Сессия отозвана: не работают ни текущий refresh-токен, ни access-токен
+			api.expect(http.StatusUnauthorized, "POST", "/api/twitter/auth/refresh", "", map[string]string{"refreshToken": tokens.RefreshToken})
+			api.expect(http.StatusUnauthorized, "POST", "/api/twitter/posts", tokens.AccessToken, map[string]string{"text": "hi"})
+		})
+	}
+}
+
+func TestRefreshTokenMalformed(t *testing.T) {
+	api := newTestAPI(t)
+	for _, token := range []string{"garbage", "zz.secret", primitive.NewObjectID().Hex() + ".", primitive.NewObjectID().Hex() + ".secret"} {
+		api.expect(http.StatusUnauthorized, "POST", "/api/twitter/auth/refresh", "", map[string]string{"refreshToken": token})
+	}
+}
+
+func TestForgedRefreshTokenKeepsSession(t *testing.T) {
+	api := newTestAPI(t)
+	_, idToken := api.register("alice")
+
+	var tokens tokenPair
+	decodeJSON(t, api.expect(http.StatusCreated, "POST", "/api/twitter/auth/login", idToken, nil), &tokens)
+	claims, err := api.srv.parseAccessToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// id сессии виден в access-токене, но без выданного токена сессию не отозвать
+	forged := claims.SessionID + ".forged-secret"
+	api.expect(http.StatusUnauthorized, "POST", "/api/twitter/auth/refresh", "", map[string]string{"refreshToken": forged})
+
+	api.expect(http.StatusCreated, "POST", "/api/twitter/posts", tokens.AccessToken, map[string]string{"text": "hi"})
+	api.expect(http.StatusOK, "POST", "/api/twitter/auth/refresh", "", map[string]string{"refreshToken": tokens.RefreshToken})
+}
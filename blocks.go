@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const collectionBlock = "blocks"
+
+// Blocker заблокировал Blocked. Между ними не создаются уведомления
+// об упоминаниях и подписки — в какую бы сторону ни шла блокировка.
+type Block struct {
+	ID         primitive.ObjectID `json:"_id" bson:"_id"`
+	Blocker    string             `json:"blocker" bson:"blocker"`
+	Blocked    string             `json:"blocked" bson:"blocked"`
+	CreateDate Timestamp          `json:"createDate" bson:"createDate"`
+}
+
+// Есть ли блокировка между пользователями в любую сторону
+func (s *server) isBlocked(ctx context.Context, a, b string) (bool, error) {
+	blocks, err := s.users.ListBlocks(ctx, a)
+	if err != nil {
+		return false, err
+	}
+	for _, block := range blocks {
+		if block.Blocker == b || block.Blocked == b {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Блокировка вызывающим пользователя {id}. Повторный запрос ничего не меняет.
+// Подписки между ними в обе стороны снимаются.
+func (s *server) blockUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	blocker := userFromContext(r.Context())
+	if blocker.ID == id {
+		http.Error(w, "Cannot block yourself", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	target, err := s.users.GetUser(ctx, id)
+	if err != nil {
+		storeError(w, err, "User not found")
+		return
+	}
+
+	created, err := s.users.Block(ctx, *blocker, target)
+	if err != nil {
+		storeError(w, err, "User not found")
+		return
+	}
+	for _, pair := range [][2]primitive.ObjectID{{blocker.ID, id}, {id, blocker.ID}} {
+		if _, err := s.users.Unfollow(ctx, pair[0], pair[1]); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+
+	json.NewEncoder(w).Encode(map[string]bool{"blocked": true})
+}
+
+func (s *server) unblockUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	blocker := userFromContext(r.Context())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := s.users.Unblock(ctx, blocker.ID, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]bool{"blocked": false})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestBlockPreventsFollow(t *testing.T) {
+	api := newTestAPI(t)
+	alice, aliceToken := api.register("alice")
+	bob, bobToken := api.register("bob")
+	ctx := context.Background()
+
+	follow := func(target User) string { return "/api/twitter/users/" + target.ID.Hex() + "/follow" }
+	api.expect(http.StatusCreated, "POST", follow(alice), bobToken, nil)
+	api.expect(http.StatusCreated, "POST", follow(bob), aliceToken, nil)
+
+	// Блокировка снимает подписки в обе стороны и не даёт подписаться снова
+	block := "/api/twitter/users/" + bob.ID.Hex() + "/block"
+	api.expect(http.StatusCreated, "POST", block, aliceToken, nil)
+	follows, err := api.store.ListFollowing(ctx, []string{alice.ID.Hex(), bob.ID.Hex()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(follows) != 0 {
+		t.Errorf("follows after block = %+v", follows)
+	}
+	api.expect(http.StatusForbidden, "POST", follow(alice), bobToken, nil)
+	api.expect(http.StatusForbidden, "POST", follow(bob), aliceToken, nil)
+
+	blocks, _ := api.store.ListBlocks(ctx, bob.ID.Hex())
+	if len(blocks) != 1 || blocks[0].Blocker != alice.ID.Hex() || blocks[0].Blocked != bob.ID.Hex() {
+		t.Errorf("blocks = %+v", blocks)
+	}
+
+	api.expect(http.StatusOK, "DELETE", block, aliceToken, nil)
+	api.expect(http.StatusCreated, "POST", follow(alice), bobToken, nil)
+	if blocks, _ := api.store.ListBlocks(ctx, bob.ID.Hex()); len(blocks) != 0 {
+		t.Errorf("blocks after unblock = %+v", blocks)
+	}
+}
+
+func TestDeleteUserRemovesBlocks(t *testing.T) {
+	api := newTestAPI(t)
+	alice, aliceToken := api.register("alice")
+	bob, _ := api.register("bob")
+
+	api.expect(http.StatusCreated, "POST", "/api/twitter/users/"+bob.ID.Hex()+"/block", aliceToken, nil)
+	api.expect(http.StatusOK, "DELETE", "/api/twitter/users/"+alice.ID.Hex(), aliceToken, nil)
+	if blocks, _ := api.store.ListBlocks(context.Background(), bob.ID.Hex()); len(blocks) != 0 {
+		t.Errorf("blocks after delete = %+v", blocks)
+	}
+}
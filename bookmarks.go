@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Закладка в приватном списке; Post пуст, если пост уже удалён
+type BookmarkItem struct {
+	ID         primitive.ObjectID `json:"_id"`
+	CreateDate Timestamp          `json:"createDate"`
+	Post       *Post              `json:"post"`
+}
+
+func (s *server) bookmarkPost(w http.ResponseWriter, r *http.Request) {
+	s.setBookmark(w, r, true)
+}
+
+func (s *server) unbookmarkPost(w http.ResponseWriter, r *http.Request) {
+	s.setBookmark(w, r, false)
+}
+
+// Закладки идемпотентны, как и лайки
+func (s *server) setBookmark(w http.ResponseWriter, r *http.Request, bookmark bool) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	user := userFromContext(r.Context())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var changed bool
+	if bookmark {
+		post, err := s.posts.GetPost(ctx, id)
+		if err != nil {
+			storeError(w, err, "Post not found")
+			return
+		}
+		changed, err = s.posts.BookmarkPost(ctx, *user, post)
+		if err != nil {
+			storeError(w, err, "Post not found")
+			return
+		}
+	} else if _, err := s.posts.UnbookmarkPost(ctx, user.ID, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if bookmark && changed {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(map[string]bool{"bookmarked": bookmark})
+}
+
+// Закладки вызывающего, от новых к старым. Видны только владельцу.
+func (s *server) getMyBookmarks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	page, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user := userFromContext(r.Context())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	bookmarks, err := s.posts.ListBookmarks(ctx, user.ID, page)
+	if err != nil {
+		storeError(w, err, "User not found")
+		return
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(bookmarks))
+	for _, b := range bookmarks {
+		if id, err := primitive.ObjectIDFromHex(b.Post); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	posts, err := s.posts.ListPostsByIDs(ctx, ids)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydratePosts(ctx, posts); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	byID := make(map[string]*Post, len(posts))
+	for i := range posts {
+		byID[posts[i].ID.Hex()] = &posts[i]
+	}
+
+	items := make([]BookmarkItem, 0, len(bookmarks))
+	for _, b := range bookmarks {
+		items = append(items, BookmarkItem{ID: b.ID, CreateDate: b.CreateDate, Post: byID[b.Post]})
+	}
+
+	writePage(w, items, page, func(item BookmarkItem) primitive.ObjectID { return item.ID })
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Глубина ответа: у комментариев к посту 0, у ответа — глубина родителя + 1
+const maxCommentDepth = 4
+
+// Комментарий с числом прямых ответов
+type CommentView struct {
+	Comment
+	Replies int `json:"replies"`
+}
+
+type commentRequest struct {
+	Text     string `json:"text"`
+	ParentID string `json:"parentId"`
+}
+
+// Читает {id} и {commentId} из пути
+func commentVars(r *http.Request) (postID, commentID primitive.ObjectID, err error) {
+	params := mux.Vars(r)
+	if postID, err = primitive.ObjectIDFromHex(params["id"]); err != nil {
+		return
+	}
+	commentID, err = primitive.ObjectIDFromHex(params["commentId"])
+	if err == nil && commentID.IsZero() {
+		err = primitive.ErrInvalidHex
+	}
+	return
+}
+
+func findComment(post Post, id primitive.ObjectID) (Comment, bool) {
+	for _, c := range post.Comments {
+		if !c.ID.IsZero() && c.ID == id {
+			return c, true
+		}
+	}
+	return Comment{}, false
+}
+
+func (s *server) createComment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var req commentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Text = strings.TrimSpace(req.Text)
+	if req.Text == "" {
+		http.Error(w, "Text is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	post, err := s.posts.GetPost(ctx, id)
+	if err != nil {
+		storeError(w, err, "Post not found")
+		return
+	}
+
+	comment := Comment{
+		ID:         primitive.NewObjectID(),
+		Text:       req.Text,
+		Author:     userFromContext(r.Context()).ID.Hex(),
+		CreateDate: timestampNow(),
+	}
+
+	if req.ParentID != "" {
+		parentID, err := primitive.ObjectIDFromHex(req.ParentID)
+		if err != nil {
+			http.Error(w, "Invalid parentId", http.StatusBadRequest)
+			return
+		}
+		parent, ok := findComment(post, parentID)
+		if !ok {
+			http.Error(w, "Parent comment not found", http.StatusNotFound)
+			return
+		}
+		if parent.Depth >= maxCommentDepth {
+			http.Error(w, "Reply depth limit reached", http.StatusBadRequest)
+			return
+		}
+		comment.ParentID = parent.ID.Hex()
+		comment.Depth = parent.Depth + 1
+	}
+
+	if comment.Mentions, err = s.resolveMentions(ctx, comment.Text); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.posts.AddComment(ctx, id, comment); err != nil {
+		storeError(w, err, "Post not found")
+		return
+	}
+	s.notifyMentions(ctx, comment.Author, id, comment.ID.Hex(), comment.Mentions)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(comment)
+}
+
+// Комментарии к посту (?parentId= — ответы на комментарий), от новых к старым
+func (s *server) getComments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	page, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	post, err := s.posts.GetPost(ctx, id)
+	if err != nil {
+		storeError(w, err, "Post not found")
+		return
+	}
+
+	parentID := r.URL.Query().Get("parentId")
+	replies := make(map[string]int)
+	for _, c := range post.Comments {
+		if c.ParentID != "" {
+			replies[c.ParentID]++
+		}
+	}
+
+	views := []CommentView{}
+	for _, c := range post.Comments {
+		if c.ParentID == parentID {
+			views = append(views, CommentView{Comment: c, Replies: replies[c.ID.Hex()]})
+		}
+	}
+
+	idOf := func(c CommentView) primitive.ObjectID { return c.ID }
+	writePage(w, pageItems(views, page, idOf), page, idOf)
+}
+
+// Редактировать комментарий может только его автор
+func (s *server) updateComment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	postID, commentID, err := commentVars(r)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var req commentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Text = strings.TrimSpace(req.Text)
+	if req.Text == "" {
+		http.Error(w, "Text is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	post, err := s.posts.GetPost(ctx, postID)
+	if err != nil {
+		storeError(w, err, "Post not found")
+		return
+	}
+	comment, ok := findComment(post, commentID)
+	if !ok {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+	if !authorize(w, r, comment.Author) {
+		return
+	}
+
+	mentions, err := s.resolveMentions(ctx, req.Text)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := timestampNow()
+	comment.Text = req.Text
+	comment.Mentions = mentions
+	comment.EditDate = &now
+	if err := s.posts.UpdateComment(ctx, postID, comment); err != nil {
+		storeError(w, err, "Comment not found")
+		return
+	}
+	s.notifyMentions(ctx, comment.Author, postID, comment.ID.Hex(), mentions)
+
+	json.NewEncoder(w).Encode(comment)
+}
+
+// Удаляет комментарий вместе со всей веткой ответов на него
+func (s *server) deleteComment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	postID, commentID, err := commentVars(r)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	post, err := s.posts.GetPost(ctx, postID)
+	if err != nil {
+		storeError(w, err, "Post not found")
+		return
+	}
+	comment, ok := findComment(post, commentID)
+	if !ok {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+	if !authorize(w, r, comment.Author) {
+		return
+	}
+
+	if err := s.posts.DeleteComments(ctx, postID, commentThread(post, comment.ID)); err != nil {
+		storeError(w, err, "Post not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Comment deleted successfully"})
+}
+
+// id комментария и всех его потомков
+func commentThread(post Post, root primitive.ObjectID) []primitive.ObjectID {
+	children := make(map[string][]primitive.ObjectID)
+	for _, c := range post.Comments {
+		if c.ParentID != "" {
+			children[c.ParentID] = append(children[c.ParentID], c.ID)
+		}
+	}
+
+	ids := []primitive.ObjectID{root}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i].Hex()]...)
+	}
+	return ids
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultConfigFile = "config.yaml"
+
+var environments = []string{"dev", "staging", "prod"}
+
+// Secret — строка, которая никогда не попадает в логи целиком
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "[redacted]"
+}
+
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+type MongoConfig struct {
+	URI      Secret `yaml:"uri"`
+	Database string `yaml:"database"`
+}
+
+type CloudinaryConfig struct {
+	CloudName string `yaml:"cloudName"`
+	APIKey    string `yaml:"apiKey"`
+	APISecret Secret `yaml:"apiSecret"`
+	// Ключ token-based authentication для ссылок на приватные файлы
+	AuthTokenKey Secret `yaml:"authTokenKey"`
+}
+
+// Хранилище загружаемых файлов: cloudinary или local
+type MediaConfig struct {
+	Backend    string `yaml:"backend"`
+	Dir        string `yaml:"dir"`
+	BaseURL    string `yaml:"baseUrl"`
+	SigningKey Secret `yaml:"signingKey"`
+}
+
+type GoogleConfig struct {
+	ClientID string `yaml:"clientId"`
+	JWKSURL  string `yaml:"jwksUrl"`
+}
+
+type SessionConfig struct {
+	Secret Secret `yaml:"secret"`
+}
+
+type Config struct {
+	Env        string           `yaml:"env"`
+	Port       string           `yaml:"port"`
+	Mongo      MongoConfig      `yaml:"mongo"`
+	Cloudinary CloudinaryConfig `yaml:"cloudinary"`
+	Media      MediaConfig      `yaml:"media"`
+	Google     GoogleConfig     `yaml:"google"`
+	Session    SessionConfig    `yaml:"session"`
+}
+
+// Файл конфигурации: общие значения плюс переопределения по окружениям
+type configFile struct {
+	Config       `yaml:",inline"`
+	Environments map[string]yaml.Node `yaml:"environments"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		Env:  "dev",
+		Port: "7070",
+		Media: MediaConfig{
+			Backend: "cloudinary",
+			Dir:     "uploads",
+			BaseURL: mediaRoute,
+		},
+		Google: GoogleConfig{JWKSURL: googleJWKSURL},
+	}
+}
+
+// loadConfig собирает конфигурацию в порядке приоритета:
+// значения по умолчанию, файл, секция environments.<env> файла, переменные окружения.
+func loadConfig() (*Config, error) {
+	cfg := defaultConfig()
+
+	env := os.Getenv("APP_ENV")
+	if env == "" {
+		env = cfg.Env
+	}
+
+	path := os.Getenv("CONFIG_FILE")
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigFile
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		var file configFile
+		file.Config = cfg
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("config: parse %s: %w", path, err)
+		}
+		cfg = file.Config
+		if node, ok := file.Environments[env]; ok {
+			if err := node.Decode(&cfg); err != nil {
+				return nil, fmt.Errorf("config: parse %s environments.%s: %w", path, env, err)
+			}
+		}
+	case explicit || !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("config: read %s: %w", path, err)
+	}
+	cfg.Env = env
+
+	overrideFromEnv(&cfg.Port, "PORT")
+	overrideFromEnv((*string)(&cfg.Mongo.URI), "MONGO_URI")
+	overrideFromEnv(&cfg.Mongo.Database, "MONGO_DATABASE")
+	overrideFromEnv(&cfg.Cloudinary.CloudName, "CLOUDINARY_CLOUD_NAME")
+	overrideFromEnv(&cfg.Cloudinary.APIKey, "CLOUDINARY_API_KEY")
+	overrideFromEnv((*string)(&cfg.Cloudinary.APISecret), "CLOUDINARY_API_SECRET")
+	overrideFromEnv((*string)(&cfg.Cloudinary.AuthTokenKey), "CLOUDINARY_AUTH_TOKEN_KEY")
+	overrideFromEnv(&cfg.Media.Backend, "MEDIA_BACKEND")
+	overrideFromEnv(&cfg.Media.Dir, "MEDIA_DIR")
+	overrideFromEnv(&cfg.Media.BaseURL, "MEDIA_BASE_URL")
+	overrideFromEnv((*string)(&cfg.Media.SigningKey), "MEDIA_SIGNING_KEY")
+	overrideFromEnv(&cfg.Google.ClientID, "GOOGLE_CLIENT_ID")
+	overrideFromEnv(&cfg.Google.JWKSURL, "GOOGLE_JWKS_URL")
+	overrideFromEnv((*string)(&cfg.Session.Secret), "SESSION_SECRET")
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func overrideFromEnv(field *string, name string) {
+	if v, ok := os.LookupEnv(name); ok && v != "" {
+		*field = v
+	}
+}
+
+func (c *Config) validate() error {
+	var errs []error
+	required := func(value, name string) {
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%s is required", name))
+		}
+	}
+
+	validEnv := false
+	for _, e := range environments {
+		if c.Env == e {
+			validEnv = true
+			break
+		}
+	}
+	if !validEnv {
+		errs = append(errs, fmt.Errorf("env must be one of %v, got %q", environments, c.Env))
+	}
+
+	required(c.Port, "port")
+	required(string(c.Mongo.URI), "mongo.uri")
+	required(c.Mongo.Database, "mongo.database")
+	switch c.Media.Backend {
+	case "cloudinary":
+		required(c.Cloudinary.CloudName, "cloudinary.cloudName")
+		required(c.Cloudinary.APIKey, "cloudinary.apiKey")
+		required(string(c.Cloudinary.APISecret), "cloudinary.apiSecret")
+		required(string(c.Cloudinary.AuthTokenKey), "cloudinary.authTokenKey")
+		if _, err := hex.DecodeString(string(c.Cloudinary.AuthTokenKey)); err != nil {
+			errs = append(errs, errors.New("cloudinary.authTokenKey must be hex"))
+		}
+	case "local":
+		required(c.Media.Dir, "media.dir")
+		required(c.Media.BaseURL, "media.baseUrl")
+		// Ключ подписи ссылок отдельный: утечка одного не раскрывает другой
+		if len(c.Media.SigningKey) < 32 {
+			errs = append(errs, errors.New("media.signingKey must be at least 32 bytes"))
+		} else if c.Media.SigningKey == c.Session.Secret {
+			errs = append(errs, errors.New("media.signingKey must differ from session.secret"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("media.backend must be cloudinary or local, got %q", c.Media.Backend))
+	}
+	required(c.Google.ClientID, "google.clientId")
+	required(c.Google.JWKSURL, "google.jwksUrl")
+	if len(c.Session.Secret) < 32 {
+		errs = append(errs, errors.New("session.secret must be at least 32 bytes"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("config: %w", errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const noticeTypeFollow = "follow"
+
+// Подписка вызывающего на пользователя {id}. Повторный запрос ничего не меняет.
+func (s *server) followUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	follower := userFromContext(r.Context())
+	if follower.ID == id {
+		http.Error(w, "Cannot follow yourself", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	target, err := s.users.GetUser(ctx, id)
+	if err != nil {
+		storeError(w, err, "User not found")
+		return
+	}
+	blocked, err := s.isBlocked(ctx, follower.ID.Hex(), target.ID.Hex())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		http.Error(w, "Blocked", http.StatusForbidden)
+		return
+	}
+
+	created, err := s.users.Follow(ctx, *follower, target)
+	if err != nil {
+		storeError(w, err, "User not found")
+		return
+	}
+
+	if created {
+		notice := Notice{
+			ID:         primitive.NewObjectID(),
+			User:       target.ID.Hex(),
+			Type:       noticeTypeFollow,
+			FromUser:   []FromUser{{ID: primitive.NewObjectID().Hex(), IDUser: follower.ID.Hex()}},
+			CreateDate: timestampNow(),
+		}
+		if err := s.notices.CreateNotice(ctx, notice); err != nil {
+			log.Printf("Error creating follow notice: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}
+
+	json.NewEncoder(w).Encode(map[string]bool{"following": true})
+}
+
+func (s *server) unfollowUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	follower := userFromContext(r.Context())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := s.users.Unfollow(ctx, follower.ID, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]bool{"following": false})
+}
@@ -4,8 +4,10 @@ go 1.24.0
 
 require (
 	github.com/cloudinary/cloudinary-go/v2 v2.9.1
+	github.com/golang-jwt/jwt/v5 v5.2.2
 	github.com/gorilla/mux v1.8.1
 	go.mongodb.org/mongo-driver v1.17.3
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
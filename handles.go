@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	collectionHandleHistory = "handle_history"
+
+	// Сменить handle повторно можно не раньше, чем через столько
+	handleChangeCooldown = 7 * 24 * time.Hour
+	// Столько старый handle ведёт на нового владельца и закреплён за ним
+	handleRedirectTTL = 30 * 24 * time.Hour
+)
+
+// Запись handle_history: пользователь сменил handle Old на New
+type HandleChange struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id"`
+	User      string             `json:"user" bson:"user"`
+	Old       string             `json:"old" bson:"old"`
+	New       string             `json:"new" bson:"new"`
+	ChangedAt Timestamp          `json:"changedAt" bson:"changedAt"`
+}
+
+var errHandleChanged = errors.New("handle was changed concurrently")
+
+// 3–30 латинских букв, цифр и _, хотя бы одна буква
+var handlePattern = regexp.MustCompile(`^[a-z0-9_]{3,30}$`)
+
+// Handle, которые нельзя занять: пути и служебные имена
+var reservedHandles = map[string]bool{
+	"admin": true, "administrator": true, "api": true, "auth": true,
+	"everyone": true, "hashtags": true, "help": true, "login": true, "logout": true,
+	"me": true, "mentions": true, "moderator": true, "notices": true, "null": true,
+	"posts": true, "root": true, "search": true, "settings": true, "support": true,
+	"system": true, "trends": true, "twitter": true, "undefined": true, "users": true,
+}
+
+// Приводит handle к хранимому виду (без @, в нижнем регистре)
+func normalizeHandle(handle string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+}
+
+// Сообщение об ошибке для недопустимого handle; "" — handle допустим
+func validateHandle(handle string) string {
+	if !handlePattern.MatchString(handle) {
+		return "Handle must be 3-30 characters: letters, digits or _"
+	}
+	if strings.Trim(handle, "0123456789_") == "" {
+		return "Handle must contain a letter"
+	}
+	if reservedHandles[handle] {
+		return "Handle is reserved"
+	}
+	return ""
+}
+
+// Последняя смена handle, освободившая handle не раньше since
+func (s *server) recentHandleChange(ctx context.Context, handle string, since time.Time) (HandleChange, bool, error) {
+	change, err := s.users.GetLastHandleChange(ctx, handle)
+	if errors.Is(err, errNotFound) {
+		return HandleChange{}, false, nil
+	}
+	if err != nil {
+		return HandleChange{}, false, err
+	}
+	return change, !change.ChangedAt.Before(since), nil
+}
+
+// PUT /users/{id}/handle {"handle": "..."}
+//
+// Повторная смена возможна через handleChangeCooldown (администратору —
+// сразу). Старый handle ещё handleRedirectTTL ведёт на пользователя и
+// не может быть занят другими.
+func (s *server) changeHandle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	if !authorize(w, r, id.Hex()) {
+		return
+	}
+
+	var body struct {
+		Handle string `json:"handle"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	handle := normalizeHandle(body.Handle)
+	if msg := validateHandle(handle); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := s.users.GetUser(ctx, id)
+	if err != nil {
+		storeError(w, err, "User not found")
+		return
+	}
+	if user.Handle == handle {
+		if err := s.hydrateUser(ctx, &user); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(viewUser(userFromContext(r.Context()), user))
+		return
+	}
+
+	now := timestampNow()
+	changes, err := s.users.ListHandleChanges(ctx, id.Hex())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Первая установка handle не считается сменой
+	if len(changes) > 0 && changes[0].Old != "" && !isAdmin(userFromContext(r.Context())) {
+		if next := changes[0].ChangedAt.Add(handleChangeCooldown); now.Before(next) {
+			w.Header().Set("Retry-After", next.UTC().Format(http.TimeFormat))
+			http.Error(w, "Handle was changed recently", http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	released, recent, err := s.recentHandleChange(ctx, handle, now.Add(-handleRedirectTTL))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if recent && released.User != id.Hex() {
+		http.Error(w, "Handle is reserved", http.StatusConflict)
+		return
+	}
+
+	change := HandleChange{ID: primitive.NewObjectID(), User: id.Hex(), Old: user.Handle, New: handle, ChangedAt: now}
+	switch err := s.users.ChangeHandle(ctx, change); {
+	case errors.Is(err, errConflict):
+		http.Error(w, "Handle is taken", http.StatusConflict)
+		return
+	case errors.Is(err, errHandleChanged):
+		http.Error(w, "Handle was changed concurrently", http.StatusConflict)
+		return
+	case err != nil:
+		storeError(w, err, "User not found")
+		return
+	}
+
+	user.Handle = handle
+	if err := s.hydrateUser(ctx, &user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(viewUser(userFromContext(r.Context()), user))
+}
+
+// GET /users/by-handle/{handle}
+//
+// Если handle недавно сменён, отвечает 302 на текущий handle пользователя.
+func (s *server) getUserByHandle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	handle := normalizeHandle(mux.Vars(r)["handle"])
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := s.users.GetUserByHandle(ctx, handle)
+	if errors.Is(err, errNotFound) {
+		s.redirectHandle(ctx, w, r, handle)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydrateUser(ctx, &user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(viewUser(userFromContext(r.Context()), user))
+}
+
+func (s *server) redirectHandle(ctx context.Context, w http.ResponseWriter, r *http.Request, handle string) {
+	change, recent, err := s.recentHandleChange(ctx, handle, time.Now().Add(-handleRedirectTTL))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !recent {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	// Пользователь мог с тех пор сменить handle ещё раз
+	userID, err := primitive.ObjectIDFromHex(change.User)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	user, err := s.users.GetUser(ctx, userID)
+	if err != nil || user.Handle == "" {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Location", path.Join(path.Dir(r.URL.Path), user.Handle))
+	w.WriteHeader(http.StatusFound)
+	json.NewEncoder(w).Encode(map[string]string{"handle": user.Handle, "user": user.ID.Hex()})
+}
+
+// История смен handle пользователя, от новых к старым
+func (s *server) getHandleHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	changes, err := s.users.ListHandleChanges(ctx, id.Hex())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if changes == nil {
+		changes = []HandleChange{}
+	}
+
+	json.NewEncoder(w).Encode(changes)
+}
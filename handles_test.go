@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestValidateHandle(t *testing.T) {
+	tests := []struct {
+		handle string
+		ok     bool
+	}{
+		{"alice", true},
+		{"a_1", true},
+		{"_x_", true},
+		{"ab", false},
+		{"a23456789012345678901234567890x", false},
+		{"alice!", false},
+		{"alice.b", false},
+		{"123", false},
+		{"___", false},
+		{"admin", false},
+		{"users", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.handle, func(t *testing.T) {
+			if msg := validateHandle(tt.handle); (msg == "") != tt.ok {
+				t.Errorf("validateHandle = %q, want ok %v", msg, tt.ok)
+			}
+		})
+	}
+	if got := normalizeHandle(" @Alice "); got != "alice" {
+		t.Errorf("normalizeHandle = %q", got)
+	}
+}
+
+func TestChangeHandle(t *testing.T) {
+	api := newTestAPI(t)
+	alice, aliceToken := api.register("alice")
+	bob, bobToken := api.register("bob")
+	handle := func(user User) string { return "/api/twitter/users/" + user.ID.Hex() + "/handle" }
+	byHandle := "/api/twitter/users/by-handle/"
+
+	// Сдвигает историю смен в прошлое, как будто прошло d
+	age := func(d time.Duration) {
+		for id, c := range api.store.handleHistory {
+			c.ChangedAt = newTimestamp(c.ChangedAt.Add(-d))
+			api.store.handleHistory[id] = c
+		}
+	}
+
+	api.expect(http.StatusBadRequest, "PUT", handle(alice), aliceToken, map[string]string{"handle": "x"})
+	api.expect(http.StatusBadRequest, "PUT", handle(alice), aliceToken, map[string]string{"handle": "Admin"})
+	api.expect(http.StatusForbidden, "PUT", handle(alice), bobToken, map[string]string{"handle": "alice"})
+
+	// Первая установка не запускает ожидание, handle сохраняется в нижнем регистре
+	api.expect(http.StatusOK, "PUT", handle(alice), aliceToken, map[string]string{"handle": "@Wonder"})
+	api.expect(http.StatusConflict, "PUT", handle(bob), bobToken, map[string]string{"handle": "wonder"})
+	api.expect(http.StatusOK, "PUT", handle(alice), aliceToken, map[string]string{"handle": "alice"})
+
+	// Повторная смена — только после handleChangeCooldown
+	rec := api.do("PUT", handle(alice), aliceToken, map[string]string{"handle": "alice2"})
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("second change: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	// Старый handle ведёт на новый и закреплён за прежним владельцем
+	rec = api.do("GET", byHandle+"wonder", "", nil)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != byHandle+"alice" {
+		t.Fatalf("old handle: status %d, Location %q", rec.Code, rec.Header().Get("Location"))
+	}
+	api.expect(http.StatusConflict, "PUT", handle(bob), bobToken, map[string]string{"handle": "wonder"})
+	api.expect(http.StatusNotFound, "GET", byHandle+"nobody", "", nil)
+
+	var user PublicUser
+	decodeJSON(t, api.expect(http.StatusOK, "GET", byHandle+"ALICE", "", nil), &user)
+	if user.ID != alice.ID {
+		t.Errorf("by handle = %+v", user)
+	}
+
+	age(handleChangeCooldown)
+	api.expect(http.StatusOK, "PUT", handle(alice), aliceToken, map[string]string{"handle": "alice2"})
+
+	// По истечении handleRedirectTTL старый handle свободен
+	age(handleRedirectTTL)
+	api.expect(http.StatusNotFound, "GET", byHandle+"wonder", "", nil)
+	api.expect(http.StatusOK, "PUT", handle(bob), bobToken, map[string]string{"handle": "wonder"})
+
+	var history []HandleChange
+	decodeJSON(t, api.expect(http.StatusOK, "GET", "/api/twitter/users/"+alice.ID.Hex()+"/handles", "", nil), &history)
+	if len(history) != 3 || history[0].New != "alice2" || history[2].Old != "" {
+		t.Errorf("history = %+v", history)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxHashtagLength = 100
+
+	// Как часто фоновая задача пересчитывает тренды
+	trendsRefreshInterval = 5 * time.Minute
+	trendsLimit           = 20
+)
+
+// Окно трендов: учитываются посты за Window, вес поста убывает вдвое
+// каждые HalfLife
+type trendWindow struct {
+	Name     string
+	Window   time.Duration
+	HalfLife time.Duration
+}
+
+var trendWindows = []trendWindow{
+	{Name: "1h", Window: time.Hour, HalfLife: 15 * time.Minute},
+	{Name: "24h", Window: 24 * time.Hour, HalfLife: 6 * time.Hour},
+}
+
+// Хештег в окне трендов
+type Trend struct {
+	Tag   string  `json:"tag"`
+	Posts int     `json:"posts"`
+	Score float64 `json:"score"`
+}
+
+// # в начале текста или после символа, который не может быть частью слова
+// (иначе «a#b» или «&#39;» считались бы хештегами)
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&/])#([\p{L}\p{N}_]+)`)
+
+// Хештеги текста без #, в нижнем регистре, без повторов, в порядке появления.
+// Теги только из цифр (#1) не считаются.
+func extractHashtags(text string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, m := range hashtagPattern.FindAllStringSubmatch(text, -1) {
+		tag := normalizeHashtag(m[1])
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// Приводит тег из текста или URL к виду, в котором он хранится; "" — не тег
+func normalizeHashtag(tag string) string {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	if tag == "" || len([]rune(tag)) > maxHashtagLength {
+		return ""
+	}
+	hasLetter := false
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return ""
+		}
+		if unicode.IsLetter(r) || r == '_' {
+			hasLetter = true
+		}
+	}
+	if !hasLetter {
+		return ""
+	}
+	return tag
+}
+
+// Вес поста возраста age при периоде полураспада halfLife
+func trendWeight(age, halfLife time.Duration) float64 {
+	return math.Exp2(-age.Seconds() / halfLife.Seconds())
+}
+
+// Посты с хештегом, от новых к старым
+func (s *server) getHashtagPosts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	tag := normalizeHashtag(mux.Vars(r)["tag"])
+	if tag == "" {
+		http.Error(w, "Invalid hashtag", http.StatusBadRequest)
+		return
+	}
+
+	page, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	posts, err := s.posts.ListPostsByHashtag(ctx, tag, page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydratePosts(ctx, posts); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writePage(w, posts, page, func(p Post) primitive.ObjectID { return p.ID })
+}
+
+// Последний расчёт трендов по окнам. Считает фоновая задача,
+// обработчик только читает.
+type trendCache struct {
+	mu        sync.RWMutex
+	trends    map[string][]Trend
+	updatedAt map[string]time.Time
+}
+
+func newTrendCache() *trendCache {
+	return &trendCache{trends: map[string][]Trend{}, updatedAt: map[string]time.Time{}}
+}
+
+func (c *trendCache) get(window string) ([]Trend, time.Time, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	trends, ok := c.trends[window]
+	return trends, c.updatedAt[window], ok
+}
+
+func (c *trendCache) set(window string, trends []Trend, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trends[window] = trends
+	c.updatedAt[window] = at
+}
+
+func (s *server) refreshTrends(ctx context.Context) error {
+	now := time.Now()
+	for _, tw := range trendWindows {
+		trends, err := s.posts.TrendingHashtags(ctx, now.Add(-tw.Window), now, tw.HalfLife, trendsLimit)
+		if err != nil {
+			return err
+		}
+		if trends == nil {
+			trends = []Trend{}
+		}
+		s.trends.set(tw.Name, trends, now)
+	}
+	return nil
+}
+
+// Пересчитывает тренды сразу и затем каждые interval, пока ctx не отменён
+func (s *server) runTrends(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		refreshCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		if err := s.refreshTrends(refreshCtx); err != nil {
+			log.Printf("Error refreshing trends: %v", err)
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// GET /trends?window=1h|24h&limit=
+func (s *server) getTrends(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = trendWindows[0].Name
+	}
+	valid := false
+	for _, tw := range trendWindows {
+		valid = valid || tw.Name == window
+	}
+	if !valid {
+		http.Error(w, "Invalid window", http.StatusBadRequest)
+		return
+	}
+
+	limit := trendsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, trendsLimit)
+	}
+
+	trends, updatedAt, ok := s.trends.get(window)
+	if !ok {
+		// Фоновая задача ещё не отработала (или не запущена)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.refreshTrends(ctx); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		trends, updatedAt, _ = s.trends.get(window)
+	}
+	if len(trends) > limit {
+		trends = trends[:limit]
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"window":    window,
+		"updatedAt": newTimestamp(updatedAt),
+		"trends":    trends,
+	})
+}
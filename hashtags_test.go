@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"#Go and #go", []string{"go"}},
+		{"hi #go_lang, #Привет!", []string{"go_lang", "привет"}},
+		{"#1 #2024 #v2", []string{"v2"}},
+		{"a#b &#39; http://x/#frag", []string{}},
+		{"(#one),#two", []string{"one", "two"}},
+		{"#", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := extractHashtags(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("hashtags = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrendingHashtagsDecay(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	halfLife := time.Hour
+
+	add := func(age time.Duration, tags ...string) {
+		created := now.Add(-age)
+		post := Post{ID: primitive.NewObjectIDFromTimestamp(created), Author: "a", Hashtags: tags, CreateDate: newTimestamp(created)}
+		if err := store.CreatePost(ctx, post); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Три старых поста весят 3 × 1/4, один свежий — почти 1
+	for range 3 {
+		add(2*time.Hour, "old")
+	}
+	add(time.Second, "fresh")
+	add(time.Hour, "fresh")
+	// За пределами окна и из будущего не считаются
+	add(5*time.Hour, "old", "gone")
+	add(-time.Minute, "gone")
+
+	trends, err := store.TrendingHashtags(ctx, now.Add(-4*time.Hour), now, halfLife, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trends) != 2 || trends[0].Tag != "fresh" || trends[1].Tag != "old" {
+		t.Fatalf("trends = %+v", trends)
+	}
+	if trends[0].Posts != 2 || trends[1].Posts != 3 {
+		t.Errorf("post counts = %d, %d", trends[0].Posts, trends[1].Posts)
+	}
+	if math.Abs(trends[1].Score-0.75) > 1e-9 || math.Abs(trends[0].Score-1.5) > 1e-3 {
+		t.Errorf("scores = %v, %v, want 1.5, 0.75", trends[0].Score, trends[1].Score)
+	}
+
+	if trends, _ := store.TrendingHashtags(ctx, now.Add(-4*time.Hour), now, halfLife, 1); len(trends) != 1 {
+		t.Errorf("limit 1 returned %d trends", len(trends))
+	}
+}
+
+func TestHashtagEndpoints(t *testing.T) {
+	api := newTestAPI(t)
+	_, token := api.register("alice")
+	id := api.createPost(token, "learning #Go")
+	api.createPost(token, "no tags")
+
+	var page pageResponse[Post]
+	decodeJSON(t, api.expect(http.StatusOK, "GET", "/api/twitter/hashtags/GO/posts", "", nil), &page)
+	if len(page.Data) != 1 || page.Data[0].ID != id {
+		t.Errorf("posts by hashtag = %+v", page.Data)
+	}
+	api.expect(http.StatusBadRequest, "GET", "/api/twitter/hashtags/123/posts", "", nil)
+
+	// Правка текста пересчитывает хештеги
+	api.expect(http.StatusOK, "PATCH", "/api/twitter/posts/"+id.Hex(), token, map[string]string{"text": "now #rust"})
+	decodeJSON(t, api.expect(http.StatusOK, "GET", "/api/twitter/hashtags/go/posts", "", nil), &page)
+	if len(page.Data) != 0 {
+		t.Errorf("posts by stale hashtag = %+v", page.Data)
+	}
+
+	var trends struct {
+		Window string  `json:"window"`
+		Trends []Trend `json:"trends"`
+	}
+	decodeJSON(t, api.expect(http.StatusOK, "GET", "/api/twitter/trends?window=24h", "", nil), &trends)
+	if trends.Window != "24h" || len(trends.Trends) != 1 || trends.Trends[0].Tag != "rust" {
+		t.Errorf("trends = %+v", trends)
+	}
+	api.expect(http.StatusBadRequest, "GET", "/api/twitter/trends?window=7d", "", nil)
+}
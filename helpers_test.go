@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const testClientID = "test-client-id"
+
+// Заглушка JWKS-эндпоинта Google с локально сгенерированными ключами
+type testJWKS struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PrivateKey
+	fetches int
+}
+
+func newTestJWKS(t *testing.T) *testJWKS {
+	t.Helper()
+	j := &testJWKS{keys: map[string]*rsa.PrivateKey{}}
+	j.Server = httptest.NewServer(http.HandlerFunc(j.serve))
+	t.Cleanup(j.Close)
+	return j
+}
+
+func (j *testJWKS) serve(w http.ResponseWriter, r *http.Request) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.fetches++
+
+	keys := []jwk{}
+	for kid, key := range j.keys {
+		keys = append(keys, jwk{
+			Kid: kid,
+			Kty: "RSA",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	json.NewEncoder(w).Encode(map[string][]jwk{"keys": keys})
+}
+
+// Генерирует ключ и публикует его под kid
+func (j *testJWKS) addKey(t *testing.T, kid string) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.mu.Lock()
+	j.keys[kid] = key
+	j.mu.Unlock()
+	return key
+}
+
+func (j *testJWKS) fetchCount() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.fetches
+}
+
+// Claims валидного Google ID-токена для пользователя sub
+func googleTestClaims(sub string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            "https://accounts.google.com",
+		"aud":            testClientID,
+		"sub":            sub,
+		"email":          sub + "@example.com",
+		"email_verified": true,
+		"name":           sub,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+	}
+}
+
+func signTestToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// API поверх memoryStore и локального хранилища файлов
+type testAPI struct {
+	t       *testing.T
+	store   *memoryStore
+	srv     *server
+	handler http.Handler
+	jwks    *testJWKS
+	key     *rsa.PrivateKey
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	jwks := newTestJWKS(t)
+	key := jwks.addKey(t, "test-key")
+
+	media, err := newLocalMedia(t.TempDir(), mediaRoute, []byte("test-media-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := newMemoryStore()
+	srv := newServer(store, media, newGoogleVerifier(jwks.URL, testClientID), []byte("test-session-secret-0123456789abcdef"))
+	return &testAPI{t: t, store: store, srv: srv, handler: srv.routes(), jwks: jwks, key: key}
+}
+
+// Google ID-токен пользователя sub
+func (a *testAPI) token(sub string) string {
+	return signTestToken(a.t, a.key, "test-key", googleTestClaims(sub))
+}
+
+func (a *testAPI) do(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	a.t.Helper()
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		r = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, r)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	a.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// multipart-запрос: files — содержимое файлов по именам полей
+func (a *testAPI) doForm(method, path, token string, fields map[string]string, files map[string][]byte) *httptest.ResponseRecorder {
+	a.t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	for k, data := range files {
+		fw, err := mw.CreateFormFile(k, k+".bin")
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		fw.Write(data)
+	}
+	mw.Close()
+	req := httptest.NewRequest(method, path, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	a.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// Выполняет запрос и проверяет код ответа; возвращает тело
+func (a *testAPI) expect(code int, method, path, token string, body interface{}) []byte {
+	a.t.Helper()
+	rec := a.do(method, path, token, body)
+	if rec.Code != code {
+		a.t.Fatalf("%s %s: status %d, want %d: %s", method, path, rec.Code, code, rec.Body.String())
+	}
+	return rec.Body.Bytes()
+}
+
+// Регистрирует пользователя sub и возвращает его вместе с токеном
+func (a *testAPI) register(sub string) (User, string) {
+	a.t.Helper()
+	token := a.token(sub)
+	a.expect(http.StatusCreated, "POST", "/api/twitter/users", token, map[string]string{"name": sub})
+	user, err := a.store.GetUserByGoogleID(context.Background(), sub)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return user, token
+}
+
+func (a *testAPI) registerAdmin(sub string) (User, string) {
+	a.t.Helper()
+	user, token := a.register(sub)
+	user.Role = roleAdmin
+	if _, err := a.store.UpdateUser(context.Background(), user.ID, user, []string{"role"}); err != nil {
+		a.t.Fatal(err)
+	}
+	return user, token
+}
+
+func (a *testAPI) createPost(token, text string) primitive.ObjectID {
+	a.t.Helper()
+	var post Post
+	decodeJSON(a.t, a.expect(http.StatusCreated, "POST", "/api/twitter/posts", token, map[string]string{"text": text}), &post)
+	return post.ID
+}
+
+func decodeJSON(t *testing.T, data []byte, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Массивы связей в User и Post не хранятся в их документах, а собираются
+// из follows, likes и репостов — чтобы ответы API остались прежними.
+
+// Заполняет подписки, подписчиков, лайки и репосты пользователей.
+// Связи всех пользователей читаются одним запросом на коллекцию.
+func (s *server) hydrateUsers(ctx context.Context, users []User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	userIDs := make([]string, 0, len(users))
+	for _, u := range users {
+		userIDs = append(userIDs, u.ID.Hex())
+	}
+
+	following, err := s.users.ListFollowing(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+	followers, err := s.users.ListFollowers(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+
+	var ids []primitive.ObjectID
+	for _, f := range following {
+		if id, err := primitive.ObjectIDFromHex(f.Followee); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	for _, f := range followers {
+		if id, err := primitive.ObjectIDFromHex(f.Follower); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	related, err := s.users.ListUsersByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]User, len(related))
+	for _, u := range related {
+		byID[u.ID.Hex()] = u
+	}
+
+	subscriptions := map[string][]Subscription{}
+	for _, f := range following {
+		if u, ok := byID[f.Followee]; ok {
+			subscriptions[f.Follower] = append(subscriptions[f.Follower], Subscription{User: f.Followee, Avatar: u.Avatar, Name: u.Name})
+		}
+	}
+	subscribers := map[string][]Subscriber{}
+	for _, f := range followers {
+		if u, ok := byID[f.Follower]; ok {
+			subscribers[f.Followee] = append(subscribers[f.Followee], Subscriber{User: f.Follower, Avatar: u.Avatar, Name: u.Name})
+		}
+	}
+
+	likes, err := s.posts.ListUserLikes(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+	likesByUser := map[string][]LikePost{}
+	for _, l := range likes {
+		likesByUser[l.User] = append(likesByUser[l.User], l)
+	}
+
+	reposts, err := s.posts.ListRepostsBy(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+	if err := s.attachOriginals(ctx, reposts); err != nil {
+		return err
+	}
+	repostsByUser := map[string][]Repost{}
+	for _, r := range reposts {
+		repost := Repost{Post: r.RepostOf, State: true}
+		if r.Original != nil {
+			repost.Author = r.Original.Author
+		}
+		repostsByUser[r.Author] = append(repostsByUser[r.Author], repost)
+	}
+
+	// Пустые массивы вместо null
+	for i := range users {
+		id := users[i].ID.Hex()
+		users[i].Subscriptions = append([]Subscription{}, subscriptions[id]...)
+		users[i].Subscribers = append([]Subscriber{}, subscribers[id]...)
+		users[i].LikesPosts = append([]LikePost{}, likesByUser[id]...)
+		users[i].Reposts = append([]Repost{}, repostsByUser[id]...)
+	}
+	return nil
+}
+
+func (s *server) hydrateUser(ctx context.Context, user *User) error {
+	users := []User{*user}
+	if err := s.hydrateUsers(ctx, users); err != nil {
+		return err
+	}
+	*user = users[0]
+	return nil
+}
+
+// Подставляет оригиналы и заполняет Post.Reposts
+func (s *server) hydratePosts(ctx context.Context, posts []Post) error {
+	if err := s.attachOriginals(ctx, posts); err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, p.ID.Hex())
+	}
+	reposts, err := s.posts.ListRepostsOf(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byPost := make(map[string][]PostRepost)
+	for _, r := range reposts {
+		byPost[r.RepostOf] = append(byPost[r.RepostOf], PostRepost{PostID: r.RepostOf, Author: r.Author, CreateDate: r.CreateDate})
+	}
+	for i := range posts {
+		posts[i].Reposts = byPost[posts[i].ID.Hex()]
+		if posts[i].Reposts == nil {
+			posts[i].Reposts = []PostRepost{}
+		}
+		// Посты, сохранённые до появления полей
+		if posts[i].Hashtags == nil {
+			posts[i].Hashtags = []string{}
+		}
+		if posts[i].Mentions == nil {
+			posts[i].Mentions = []Mention{}
+		}
+	}
+	return nil
+}
+
+func (s *server) hydratePost(ctx context.Context, post *Post) error {
+	posts := []Post{*post}
+	if err := s.hydratePosts(ctx, posts); err != nil {
+		return err
+	}
+	*post = posts[0]
+	return nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Индекс, который должен существовать в коллекции. Имя не задаётся:
+// MongoDB строит его из ключей (author_1__id_-1), и по нему объявленные
+// индексы сравниваются с реальными.
+type indexSpec struct {
+	Keys    bson.D
+	Unique  bool
+	Partial bson.M
+	// TTL в секундах; nil — обычный индекс
+	ExpireAfter *int32
+}
+
+func asc(field string) bson.E  { return bson.E{Key: field, Value: 1} }
+func desc(field string) bson.E { return bson.E{Key: field, Value: -1} }
+
+// TTL-индекс с нулевым сроком удаляет документ в момент, записанный в поле
+var expireAtDate int32 = 0
+
+// Все индексы приложения. Списки страниц идут по _id, который растёт
+// вместе с временем создания, поэтому «индекс по дате» — это _id.
+var declaredIndexes = map[string][]indexSpec{
+	collectionUser: {
+		{Keys: bson.D{asc("email")}, Unique: true},
+		{Keys: bson.D{asc("googleId")}, Unique: true},
+		{Keys: bson.D{asc("handle")}, Unique: true, Partial: bson.M{"handle": bson.M{"$type": "string"}}},
+		// Поиск пользователей по имени и handle; текстовый индекс в коллекции
+		// может быть только один
+		{Keys: bson.D{{Key: "name", Value: "text"}, {Key: "handle", Value: "text"}}},
+	},
+	collectionPost: {
+		{Keys: bson.D{asc("author"), desc("_id")}},
+		{Keys: bson.D{asc("repostOf")}},
+		{Keys: bson.D{{Key: "text", Value: "text"}}},
+		{Keys: bson.D{asc("hashtags"), desc("_id")}},
+		{Keys: bson.D{asc("mentions.user"), desc("_id")}},
+		{Keys: bson.D{asc("comments.mentions.user"), desc("_id")}},
+		// Тренды выбирают посты за последние сутки
+		{Keys: bson.D{asc("createDate")}},
+		{
+			Keys:    bson.D{asc("author"), asc("repostOf")},
+			Unique:  true,
+			Partial: bson.M{"repostOf": bson.M{"$exists": true}},
+		},
+	},
+	collectionChat: {
+		{Keys: bson.D{asc("idd"), desc("_id")}},
+		{Keys: bson.D{asc("author"), asc("idd")}},
+	},
+	collectionMessage: {
+		{Keys: bson.D{asc("id")}},
+		{Keys: bson.D{asc("sender"), desc("_id")}},
+		{Keys: bson.D{asc("receiver"), desc("_id")}},
+	},
+	collectionNotice: {
+		{Keys: bson.D{asc("user"), asc("read"), desc("_id")}},
+		// Дедупликация уведомлений об упоминаниях
+		{
+			Keys:    bson.D{asc("user"), asc("post"), asc("comment")},
+			Unique:  true,
+			Partial: bson.M{"type": noticeTypeMention},
+		},
+	},
+	collectionSession: {
+		{Keys: bson.D{asc("user")}},
+		// Истёкшие сессии MongoDB удаляет сама
+		{Keys: bson.D{asc("expiresAt")}, ExpireAfter: &expireAtDate},
+	},
+	collectionHandleHistory: {
+		{Keys: bson.D{asc("user"), desc("_id")}},
+		{Keys: bson.D{asc("old"), desc("_id")}},
+	},
+	collectionFollow: {
+		{Keys: bson.D{asc("follower"), asc("followee")}, Unique: true},
+		{Keys: bson.D{asc("followee")}},
+	},
+	collectionBlock: {
+		{Keys: bson.D{asc("blocker"), asc("blocked")}, Unique: true},
+		{Keys: bson.D{asc("blocked")}},
+	},
+	collectionLike: {
+		{Keys: bson.D{asc("user"), asc("post")}, Unique: true},
+		{Keys: bson.D{asc("post"), desc("_id")}},
+	},
+	collectionBookmark: {
+		{Keys: bson.D{asc("user"), asc("post")}, Unique: true},
+		{Keys: bson.D{asc("user"), desc("_id")}},
+	},
+}
+
+// Имя по умолчанию, как его строит драйвер
+func (spec indexSpec) name() string {
+	parts := make([]string, 0, len(spec.Keys))
+	for _, k := range spec.Keys {
+		parts = append(parts, fmt.Sprintf("%s_%v", k.Key, k.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+func (spec indexSpec) model() mongo.IndexModel {
+	opts := options.Index().SetName(spec.name())
+	if spec.Unique {
+		opts.SetUnique(true)
+	}
+	if spec.Partial != nil {
+		opts.SetPartialFilterExpression(spec.Partial)
+	}
+	if spec.ExpireAfter != nil {
+		opts.SetExpireAfterSeconds(*spec.ExpireAfter)
+	}
+	return mongo.IndexModel{Keys: spec.Keys, Options: opts}
+}
+
+// Индекс в том виде, в каком его возвращает listIndexes
+type existingIndex struct {
+	Name        string `bson:"name"`
+	Unique      bool   `bson:"unique"`
+	Partial     bson.M `bson:"partialFilterExpression"`
+	ExpireAfter *int32 `bson:"expireAfterSeconds"`
+}
+
+// Создаёт недостающие индексы перечисленных коллекций (всех, если не
+// указаны) и пишет в лог расхождения с объявленными. Лишние и изменённые
+// индексы не удаляются: их нужно убрать вручную или миграцией.
+// Повторный вызов ничего не меняет.
+func ensureIndexes(ctx context.Context, db *mongo.Database, collections ...string) error {
+	if len(collections) == 0 {
+		for name := range declaredIndexes {
+			collections = append(collections, name)
+		}
+	}
+
+	var errs []error
+	for _, name := range collections {
+		if err := ensureCollectionIndexes(ctx, db.Collection(name), declaredIndexes[name]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func ensureCollectionIndexes(ctx context.Context, coll *mongo.Collection, specs []indexSpec) error {
+	existing, err := listIndexes(ctx, coll)
+	if err != nil {
+		return err
+	}
+
+	declared := make(map[string]bool, len(specs))
+	var errs []error
+	for _, spec := range specs {
+		name := spec.name()
+		declared[name] = true
+		if idx, ok := existing[name]; ok {
+			if diff := spec.diff(idx); diff != "" {
+				log.Printf("Index drift: %s.%s differs from declaration (%s)", coll.Name(), name, diff)
+			}
+			continue
+		}
+
+		if _, err := coll.Indexes().CreateOne(ctx, spec.model()); err != nil {
+			// Например, уникальный индекс при дубликатах в данных
+			errs = append(errs, fmt.Errorf("create index %s: %w", name, err))
+			continue
+		}
+		log.Printf("Created index %s.%s", coll.Name(), name)
+	}
+
+	for name := range existing {
+		if name != "_id_" && !declared[name] {
+			log.Printf("Index drift: %s.%s exists but is not declared", coll.Name(), name)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func listIndexes(ctx context.Context, coll *mongo.Collection) (map[string]existingIndex, error) {
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	indexes := map[string]existingIndex{}
+	for cursor.Next(ctx) {
+		var idx existingIndex
+		if err := cursor.Decode(&idx); err != nil {
+			return nil, err
+		}
+		indexes[idx.Name] = idx
+	}
+	return indexes, cursor.Err()
+}
+
+// Описание расхождения объявленного и существующего индекса с тем же
+// именем (то есть с теми же ключами); пусто, если совпадают
+func (spec indexSpec) diff(idx existingIndex) string {
+	if spec.Unique != idx.Unique {
+		return fmt.Sprintf("unique %t, want %t", idx.Unique, spec.Unique)
+	}
+	if fmt.Sprint(spec.Partial) != fmt.Sprint(idx.Partial) {
+		return fmt.Sprintf("partial filter %v, want %v", idx.Partial, spec.Partial)
+	}
+	if !reflect.DeepEqual(spec.ExpireAfter, idx.ExpireAfter) {
+		return "expireAfterSeconds differs"
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Публичные данные лайкнувшего пользователя
+type Liker struct {
+	ID     primitive.ObjectID `json:"_id"`
+	Name   string             `json:"name"`
+	Avatar string             `json:"avatar"`
+
+	cursor primitive.ObjectID
+}
+
+type likeResponse struct {
+	Liked bool `json:"liked"`
+	Likes int  `json:"likes"`
+}
+
+func (s *server) likePost(w http.ResponseWriter, r *http.Request) {
+	s.setLike(w, r, true)
+}
+
+func (s *server) unlikePost(w http.ResponseWriter, r *http.Request) {
+	s.setLike(w, r, false)
+}
+
+// Лайк и снятие лайка идемпотентны: Post.Likes меняется только
+// если документ в likes действительно добавлен или удалён.
+func (s *server) setLike(w http.ResponseWriter, r *http.Request, like bool) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	user := userFromContext(r.Context())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	post, err := s.posts.GetPost(ctx, id)
+	if err != nil {
+		storeError(w, err, "Post not found")
+		return
+	}
+
+	var changed bool
+	if like {
+		changed, err = s.posts.LikePost(ctx, *user, post)
+	} else {
+		changed, err = s.posts.UnlikePost(ctx, user.ID, post.ID)
+	}
+	if err != nil {
+		storeError(w, err, "Post not found")
+		return
+	}
+
+	post, err = s.posts.GetPost(ctx, id)
+	if err != nil {
+		storeError(w, err, "Post not found")
+		return
+	}
+
+	if like && changed {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(likeResponse{Liked: like, Likes: post.Likes})
+}
+
+func (s *server) getPostLikes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	page, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := s.posts.GetPost(ctx, id); err != nil {
+		storeError(w, err, "Post not found")
+		return
+	}
+
+	likes, err := s.posts.ListLikes(ctx, id, page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(likes))
+	for _, like := range likes {
+		if userID, err := primitive.ObjectIDFromHex(like.User); err == nil {
+			ids = append(ids, userID)
+		}
+	}
+	users, err := s.users.ListUsersByIDs(ctx, ids)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	byID := make(map[string]User, len(users))
+	for _, u := range users {
+		byID[u.ID.Hex()] = u
+	}
+
+	// У удалённых аккаунтов остаётся только id
+	likers := make([]Liker, 0, len(likes))
+	for _, like := range likes {
+		u := byID[like.User]
+		id, _ := primitive.ObjectIDFromHex(like.User)
+		likers = append(likers, Liker{ID: id, Name: u.Name, Avatar: u.Avatar, cursor: like.ID})
+	}
+
+	writePage(w, likers, page, func(l Liker) primitive.ObjectID { return l.cursor })
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudinary/cloudinary-go/v2"
+	"github.com/cloudinary/cloudinary-go/v2/api"
+	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+	"github.com/cloudinary/cloudinary-go/v2/config"
+)
+
+const (
+	mediaRoute = "/media/"
+
+	// Срок жизни подписанных ссылок на приватные файлы в ответах API
+	mediaURLTTL = time.Hour
+)
+
+var (
+	errUnsupportedMedia = errors.New("unsupported media type")
+	errInvalidUpload    = errors.New("invalid upload")
+)
+
+// Загруженный файл: ID для удаления/подписи и URL. URL приватного файла
+// без подписи не открывается.
+type Media struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+type MediaStore interface {
+	// private — файл отдаётся только по ссылке из SignedURL
+	Upload(ctx context.Context, file io.Reader, filename string, private bool) (Media, error)
+	Delete(ctx context.Context, id string) error
+	SignedURL(ctx context.Context, id string, ttl time.Duration) (string, error)
+}
+
+// --- Cloudinary ---
+
+type cloudinaryMedia struct {
+	cld *cloudinary.Cloudinary
+	// Ключ token-based authentication (hex) из настроек Cloudinary
+	authTokenKey string
+}
+
+func newCloudinaryMedia(cld *cloudinary.Cloudinary, authTokenKey string) *cloudinaryMedia {
+	return &cloudinaryMedia{cld: cld, authTokenKey: authTokenKey}
+}
+
+// Приватные файлы загружаются с типом authenticated; тип хранится в ID
+const cloudinaryPrivatePrefix = "authenticated:"
+
+func cloudinaryAsset(id string) (string, api.DeliveryType) {
+	if publicID, ok := strings.CutPrefix(id, cloudinaryPrivatePrefix); ok {
+		return publicID, api.Authenticated
+	}
+	return id, api.Upload
+}
+
+func (m *cloudinaryMedia) Upload(ctx context.Context, file io.Reader, filename string, private bool) (Media, error) {
+	params := uploader.UploadParams{
+		Folder:         "social-network",
+		AllowedFormats: []string{"jpg", "png", "webp", "gif"},
+		Transformation: "w_1200,c_limit",
+	}
+	if private {
+		params.Type = api.Authenticated
+	}
+	uploadResult, err := m.cld.Upload.Upload(ctx, file, params)
+	if err != nil {
+		return Media{}, err
+	}
+	if uploadResult.Error.Message != "" {
+		return Media{}, errors.New(uploadResult.Error.Message)
+	}
+	media := Media{ID: uploadResult.PublicID, URL: uploadResult.SecureURL}
+	if private {
+		media.ID = cloudinaryPrivatePrefix + media.ID
+	}
+	return media, nil
+}
+
+func (m *cloudinaryMedia) Delete(ctx context.Context, id string) error {
+	publicID, deliveryType := cloudinaryAsset(id)
+	_, err := m.cld.Upload.Destroy(ctx, uploader.DestroyParams{PublicID: publicID, Type: string(deliveryType)})
+	return err
+}
+
+// Приватный файл отдаётся по ссылке с auth token, который истекает через ttl.
+// Публичным файлам подпись не нужна.
+func (m *cloudinaryMedia) SignedURL(ctx context.Context, id string, ttl time.Duration) (string, error) {
+	publicID, deliveryType := cloudinaryAsset(id)
+	img, err := m.cld.Image(publicID)
+	if err != nil {
+		return "", err
+	}
+	img.DeliveryType = deliveryType
+	img.Config.URL.Secure = true
+	if deliveryType == api.Authenticated {
+		if m.authTokenKey == "" {
+			return "", errors.New("cloudinary auth token key is not configured")
+		}
+		// Config токена общий у всех ассетов клиента — подменяем копией
+		img.AuthToken.Config = &config.AuthToken{Key: m.authTokenKey, Expiration: time.Now().Add(ttl).Unix()}
+		img.Config.URL.SignURL = true
+	}
+	return img.String()
+}
+
+// --- Локальный диск ---
+
+var localMediaTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
+// Имена приватных файлов на диске начинаются с этого префикса
+const localPrivatePrefix = "private-"
+
+// localMedia хранит файлы в каталоге и раздаёт их по маршруту /media/.
+// Приватные файлы отдаются только по подписанной ссылке (expires+sig).
+type localMedia struct {
+	dir     string
+	baseURL string
+	key     []byte
+}
+
+func newLocalMedia(dir, baseURL string, key []byte) (*localMedia, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return &localMedia{dir: dir, baseURL: baseURL, key: key}, nil
+}
+
+func (m *localMedia) Upload(ctx context.Context, file io.Reader, filename string, private bool) (Media, error) {
+	br := bufio.NewReader(file)
+	head, _ := br.Peek(512)
+	ext, ok := localMediaTypes[http.DetectContentType(head)]
+	if !ok {
+		return Media{}, errUnsupportedMedia
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return Media{}, err
+	}
+	id := hex.EncodeToString(b) + ext
+	if private {
+		id = localPrivatePrefix + id
+	}
+
+	out, err := os.OpenFile(filepath.Join(m.dir, id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return Media{}, err
+	}
+	if _, err := io.Copy(out, br); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return Media{}, err
+	}
+	if err := out.Close(); err != nil {
+		return Media{}, err
+	}
+
+	return Media{ID: id, URL: m.baseURL + id}, nil
+}
+
+func (m *localMedia) path(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", errNotFound
+	}
+	return filepath.Join(m.dir, id), nil
+}
+
+func (m *localMedia) Delete(ctx context.Context, id string) error {
+	p, err := m.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); errors.Is(err, os.ErrNotExist) {
+		return errNotFound
+	} else {
+		return err
+	}
+}
+
+func (m *localMedia) sign(id string, expires int64) string {
+	mac := hmac.New(sha256.New, m.key)
+	fmt.Fprintf(mac, "%s:%d", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (m *localMedia) SignedURL(ctx context.Context, id string, ttl time.Duration) (string, error) {
+	if _, err := m.path(id); err != nil {
+		return "", err
+	}
+	expires := time.Now().Add(ttl).Unix()
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("sig", m.sign(id, expires))
+	return m.baseURL + id + "?" + q.Encode(), nil
+}
+
+func (m *localMedia) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p, err := m.path(strings.TrimPrefix(r.URL.Path, mediaRoute))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if id := filepath.Base(p); strings.HasPrefix(id, localPrivatePrefix) {
+		sig := r.URL.Query().Get("sig")
+		expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+		if sig == "" || err != nil || time.Now().Unix() > expires || !hmac.Equal([]byte(sig), []byte(m.sign(id, expires))) {
+			http.Error(w, "Invalid or expired signature", http.StatusForbidden)
+			return
+		}
+	}
+
+	http.ServeFile(w, r, p)
+}
+
+// Загружает файл из поля формы, если он передан. Пустой Media — файла нет.
+func (s *server) uploadFormFile(ctx context.Context, r *http.Request, field string, private bool) (Media, error) {
+	file, header, err := r.FormFile(field)
+	if errors.Is(err, http.ErrMissingFile) {
+		return Media{}, nil
+	}
+	if err != nil {
+		return Media{}, fmt.Errorf("%w: %v", errInvalidUpload, err)
+	}
+	defer file.Close()
+
+	return s.media.Upload(ctx, file, header.Filename, private)
+}
+
+// Ответ на ошибку загрузки: 400 для испорченного или неподдерживаемого файла
+func uploadError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errInvalidUpload) || errors.Is(err, errUnsupportedMedia) {
+		http.Error(w, "Invalid image", http.StatusBadRequest)
+		return
+	}
+	http.Error(w, "Failed to upload image", http.StatusInternalServerError)
+}
+
+// Заменяет url подписанной ссылкой, если файл id приватный
+func (s *server) signMediaURL(ctx context.Context, id string, url *string) error {
+	if id == "" {
+		return nil
+	}
+	signed, err := s.media.SignedURL(ctx, id, mediaURLTTL)
+	if err != nil {
+		return err
+	}
+	*url = signed
+	return nil
+}
+
+func (s *server) signChats(ctx context.Context, chats []Chat) error {
+	for i := range chats {
+		if err := s.signMediaURL(ctx, chats[i].ImgID, &chats[i].Img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *server) signMessages(ctx context.Context, messages []Message) error {
+	for i := range messages {
+		if err := s.signMediaURL(ctx, messages[i].ImgID, &messages[i].Img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Удаляет загруженный файл. Документ уже изменён, поэтому ошибки только в лог.
+func (s *server) deleteMedia(ctx context.Context, id string) {
+	if id == "" {
+		return
+	}
+	if err := s.media.Delete(ctx, id); err != nil && !errors.Is(err, errNotFound) {
+		log.Printf("Error deleting media %s: %v", id, err)
+	}
+}
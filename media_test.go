@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudinary/cloudinary-go/v2"
+)
+
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+
+func (a *testAPI) localMedia() *localMedia {
+	return a.srv.media.(*localMedia)
+}
+
+func (a *testAPI) mediaExists(id string) bool {
+	_, err := os.Stat(filepath.Join(a.localMedia().dir, id))
+	return err == nil
+}
+
+func TestLocalMediaSignature(t *testing.T) {
+	m, err := newLocalMedia(t.TempDir(), mediaRoute, []byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	public, err := m.Upload(ctx, bytes.NewReader(testPNG), "a.png", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := m.Upload(ctx, bytes.NewReader(testPNG), "b.png", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := m.SignedURL(ctx, private.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := m.SignedURL(ctx, private.ID, -time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{"public", public.URL, http.StatusOK},
+		{"private without signature", private.URL, http.StatusForbidden},
+		{"private with signature", signed, http.StatusOK},
+		{"private with only sig", private.URL + "?sig=" + m.sign(private.ID, 0), http.StatusForbidden},
+		{"tampered signature", strings.Replace(signed, "sig=", "sig=0", 1), http.StatusForbidden},
+		{"expired", expired, http.StatusForbidden},
+		{"path traversal", mediaRoute + "../secret", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			m.ServeHTTP(rec, httptest.NewRequest("GET", tt.url, nil))
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+
+	if _, err := m.Upload(ctx, strings.NewReader("plain text"), "a.txt", false); err != errUnsupportedMedia {
+		t.Errorf("upload text: %v, want errUnsupportedMedia", err)
+	}
+}
+
+func TestCloudinarySignedURLExpires(t *testing.T) {
+	cld, err := cloudinary.NewFromParams("demo", "key", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newCloudinaryMedia(cld, "abcdef0123456789")
+	ctx := context.Background()
+
+	signed, err := m.SignedURL(ctx, cloudinaryPrivatePrefix+"social-network/a", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	if !strings.Contains(signed, "/authenticated/") || !strings.Contains(signed, "__cld_token__=exp="+exp[:len(exp)-2]) {
+		t.Errorf("private url = %q, want an auth token expiring in an hour", signed)
+	}
+	public, err := m.SignedURL(ctx, "social-network/b", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(public, "__cld_token__") {
+		t.Errorf("public url = %q, want no token", public)
+	}
+
+	if _, err := newCloudinaryMedia(cld, "").SignedURL(ctx, cloudinaryPrivatePrefix+"a", time.Hour); err == nil {
+		t.Error("private url signed without an auth token key")
+	}
+}
+
+func TestPostImageLifecycle(t *testing.T) {
+	api := newTestAPI(t)
+	_, token := api.register("alice")
+
+	rec := api.doForm("POST", "/api/twitter/posts", token, map[string]string{"text": "pic"}, map[string][]byte{"images": testPNG})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var post Post
+	decodeJSON(t, rec.Body.Bytes(), &post)
+	stored, err := api.store.GetPost(context.Background(), post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := stored.ImagesID
+	if first == "" || !api.mediaExists(first) || post.Images != mediaRoute+first {
+		t.Fatalf("stored post = %+v", stored)
+	}
+
+	// Замена картинки удаляет старый файл, а PATCH без файла его сохраняет
+	path := "/api/twitter/posts/" + post.ID.Hex()
+	if rec := api.doForm("PATCH", path, token, nil, map[string][]byte{"images": testPNG}); rec.Code != http.StatusOK {
+		t.Fatalf("replace: status %d: %s", rec.Code, rec.Body.String())
+	}
+	api.expect(http.StatusOK, "PATCH", path, token, map[string]string{"text": "renamed"})
+	stored, _ = api.store.GetPost(context.Background(), post.ID)
+	if api.mediaExists(first) || stored.ImagesID == "" || !api.mediaExists(stored.ImagesID) {
+		t.Errorf("after replace: old exists=%v, new=%q", api.mediaExists(first), stored.ImagesID)
+	}
+
+	api.expect(http.StatusOK, "DELETE", path, token, nil)
+	if api.mediaExists(stored.ImagesID) {
+		t.Error("image left behind after post delete")
+	}
+}
+
+func TestMessageImageIsPrivate(t *testing.T) {
+	api := newTestAPI(t)
+	_, aliceToken := api.register("alice")
+	bob, _ := api.register("bob")
+
+	var message Message
+	decodeJSON(t, api.expect(http.StatusCreated, "POST", "/api/twitter/messages", aliceToken,
+		map[string]string{"id": "chat", "receiver": bob.ID.Hex()}), &message)
+	path := "/api/twitter/messages/" + message.ID.Hex()
+
+	rec := api.doForm("PATCH", path, aliceToken, nil, map[string][]byte{"img": testPNG})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	decodeJSON(t, rec.Body.Bytes(), &message)
+	stored, err := api.store.GetMessage(context.Background(), message.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stored.ImgID, localPrivatePrefix) || !strings.Contains(message.Img, "sig=") {
+		t.Fatalf("stored = %+v, returned img = %q", stored, message.Img)
+	}
+
+	api.expect(http.StatusForbidden, "GET", stored.Img, "", nil)
+	api.expect(http.StatusOK, "GET", message.Img, "", nil)
+
+	api.expect(http.StatusOK, "DELETE", path, aliceToken, nil)
+	if api.mediaExists(stored.ImgID) {
+		t.Error("image left behind after message delete")
+	}
+}
+
+func TestUploadErrors(t *testing.T) {
+	api := newTestAPI(t)
+	_, token := api.register("alice")
+
+	rec := api.doForm("POST", "/api/twitter/posts", token, map[string]string{"text": "pic"}, map[string][]byte{"images": []byte("not an image")})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unsupported file: status %d, want 400: %s", rec.Code, rec.Body.String())
+	}
+	// Без файла пост создаётся без картинки
+	rec = api.doForm("POST", "/api/twitter/posts", token, map[string]string{"text": "no pic"}, nil)
+	if rec.Code != http.StatusCreated {
+		t.Errorf("no file: status %d, want 201: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestChatImageForm(t *testing.T) {
+	api := newTestAPI(t)
+	_, token := api.register("alice")
+
+	rec := api.doForm("POST", "/api/twitter/chat", token, map[string]string{"idd": "room", "text": "hi"}, map[string][]byte{"img": testPNG})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var chat Chat
+	decodeJSON(t, rec.Body.Bytes(), &chat)
+	if chat.IDD != "room" || !strings.Contains(chat.Img, "sig=") {
+		t.Errorf("chat = %+v", chat)
+	}
+	api.expect(http.StatusOK, "GET", chat.Img, "", nil)
+}
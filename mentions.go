@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	noticeTypeMention = "mention"
+
+	// Больше упоминаний в одном тексте не разбираем: остальные остаются текстом
+	maxMentions = 10
+)
+
+// Упоминание @handle в тексте. Start и End — позиции в символах (рунах),
+// End не включается.
+type Mention struct {
+	User   string `json:"user" bson:"user"`
+	Handle string `json:"handle" bson:"handle"`
+	Start  int    `json:"start" bson:"start"`
+	End    int    `json:"end" bson:"end"`
+}
+
+// @ в начале текста или после символа, который не может быть частью
+// handle или адреса почты. Конец handle проверяет parseMentions: в RE2
+// нет lookahead.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@.])@([A-Za-z0-9_]{1,30})`)
+
+// Продолжает handle: после упоминания такого символа быть не должно
+func isHandleRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Упоминания в тексте без проверки, что пользователи существуют
+func parseMentions(text string) []Mention {
+	var mentions []Mention
+	for _, m := range mentionPattern.FindAllStringSubmatchIndex(text, -1) {
+		// Иначе совпал префикс более длинного слова, например @ + 31 символ
+		if next, _ := utf8.DecodeRuneInString(text[m[3]:]); isHandleRune(next) {
+			continue
+		}
+		if len(mentions) == maxMentions {
+			break
+		}
+		// m[2]:m[3] — handle; @ стоит прямо перед ним
+		start := utf8.RuneCountInString(text[:m[2]-1])
+		mentions = append(mentions, Mention{
+			Handle: normalizeHandle(text[m[2]:m[3]]),
+			Start:  start,
+			End:    start + 1 + utf8.RuneCountInString(text[m[2]:m[3]]),
+		})
+	}
+	return mentions
+}
+
+// Упоминания существующих пользователей; неизвестные handle пропускаются
+func (s *server) resolveMentions(ctx context.Context, text string) ([]Mention, error) {
+	parsed := parseMentions(text)
+	handles := make([]string, 0, len(parsed))
+	for _, m := range parsed {
+		handles = append(handles, m.Handle)
+	}
+	users, err := s.users.ListUsersByHandles(ctx, handles)
+	if err != nil {
+		return nil, err
+	}
+	byHandle := make(map[string]string, len(users))
+	for _, u := range users {
+		byHandle[u.Handle] = u.ID.Hex()
+	}
+
+	mentions := []Mention{}
+	for _, m := range parsed {
+		if id, ok := byHandle[m.Handle]; ok {
+			m.User = id
+			mentions = append(mentions, m)
+		}
+	}
+	return mentions, nil
+}
+
+// Уведомляет упомянутых пользователей, каждого не больше одного раза на
+// пост или комментарий (в том числе после правки текста). Автор себя
+// не уведомляет, как и тех, с кем между ними есть блокировка.
+// Ошибки только пишутся в лог: пост уже сохранён.
+func (s *server) notifyMentions(ctx context.Context, author string, postID primitive.ObjectID, commentID string, mentions []Mention) {
+	if len(mentions) == 0 {
+		return
+	}
+	blocks, err := s.users.ListBlocks(ctx, author)
+	if err != nil {
+		log.Printf("Error listing blocks: %v", err)
+		return
+	}
+	notified := map[string]bool{author: true}
+	for _, b := range blocks {
+		notified[b.Blocker], notified[b.Blocked] = true, true
+	}
+	for _, m := range mentions {
+		if notified[m.User] {
+			continue
+		}
+		notified[m.User] = true
+
+		notice := Notice{
+			ID:         primitive.NewObjectID(),
+			User:       m.User,
+			Type:       noticeTypeMention,
+			Post:       postID.Hex(),
+			Comment:    commentID,
+			FromUser:   []FromUser{{ID: primitive.NewObjectID().Hex(), IDUser: author}},
+			CreateDate: timestampNow(),
+		}
+		if _, err := s.notices.CreateNoticeOnce(ctx, notice); err != nil {
+			log.Printf("Error creating mention notice: %v", err)
+		}
+	}
+}
+
+// Посты, в которых пользователь упомянут в тексте или в комментариях
+func (s *server) getUserMentions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	page, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	posts, err := s.posts.ListMentions(ctx, id.Hex(), page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydratePosts(ctx, posts); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writePage(w, posts, page, func(p Post) primitive.ObjectID { return p.ID })
+}
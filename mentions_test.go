@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	long := strings.Repeat("a", 31)
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"@alice hi", []string{"alice"}},
+		{"hi @Alice!", []string{"alice"}},
+		{"(@bob), @carol.", []string{"bob", "carol"}},
+		{"mail alice@example.com", nil},
+		{"@@alice", nil},
+		{"@" + long, nil},
+		{"@" + long[:30] + " end", []string{long[:30]}},
+		{"@alice_ и @bob-x", []string{"alice_", "bob"}},
+		{"@aliceé", nil},
+		{"@алиса", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			var got []string
+			for _, m := range parseMentions(tt.text) {
+				got = append(got, m.Handle)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("handles = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Позиции в рунах: @ входит в упоминание
+	m := parseMentions("ёж @bob")
+	if len(m) != 1 || m[0].Start != 3 || m[0].End != 7 {
+		t.Errorf("mention = %+v, want start 3, end 7", m)
+	}
+
+	var text strings.Builder
+	for i := 0; i < maxMentions+5; i++ {
+		fmt.Fprintf(&text, "@user%d ", i)
+	}
+	if n := len(parseMentions(text.String())); n != maxMentions {
+		t.Errorf("parsed %d mentions, want %d", n, maxMentions)
+	}
+}
+
+func TestMentionNoticesRespectBlocks(t *testing.T) {
+	api := newTestAPI(t)
+	alice, aliceToken := api.register("alice")
+	bob, bobToken := api.register("bob")
+	carol, carolToken := api.register("carol")
+	for _, u := range []struct {
+		user  User
+		token string
+	}{{alice, aliceToken}, {bob, bobToken}, {carol, carolToken}} {
+		api.expect(http.StatusOK, "PUT", "/api/twitter/users/"+u.user.ID.Hex()+"/handle", u.token, map[string]string{"handle": u.user.GoogleID})
+	}
+
+	mentionNotices := func(user User) int {
+		n := 0
+		for _, notice := range api.store.notices {
+			if notice.User == user.ID.Hex() && notice.Type == noticeTypeMention {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Блокировка bob → alice: упоминания от alice не доходят до bob
+	block := "/api/twitter/users/" + alice.ID.Hex() + "/block"
+	api.expect(http.StatusCreated, "POST", block, bobToken, nil)
+	api.expect(http.StatusOK, "POST", block, bobToken, nil)
+	api.createPost(aliceToken, "hi @bob and @carol")
+	if n := mentionNotices(bob); n != 0 {
+		t.Errorf("bob got %d mention notices from a blocked user", n)
+	}
+	if n := mentionNotices(carol); n != 1 {
+		t.Errorf("carol got %d mention notices, want 1", n)
+	}
+
+	// И в обратную сторону: bob не может упоминанием достучаться до alice
+	api.createPost(bobToken, "hey @alice")
+	if n := mentionNotices(alice); n != 0 {
+		t.Errorf("alice got %d mention notices from a user who blocked alice", n)
+	}
+
+	api.expect(http.StatusOK, "DELETE", block, bobToken, nil)
+	api.createPost(aliceToken, "again @bob")
+	if n := mentionNotices(bob); n != 1 {
+		t.Errorf("after unblock bob got %d mention notices, want 1", n)
+	}
+
+	api.expect(http.StatusBadRequest, "POST", "/api/twitter/users/"+bob.ID.Hex()+"/block", bobToken, nil)
+	api.expect(http.StatusUnauthorized, "POST", block, "", nil)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Поля, которые клиент может менять через PUT/PATCH (JSON Merge Patch,
+// RFC 7396). Остальные выставляет сервер; попытка их передать — 422.
+var (
+	// messages и posts ведёт сервер
+	userPatchFields = []string{"name", "avatar"}
+	// Администратор дополнительно может менять учётные данные и роль
+	userAdminPatchFields = []string{"email", "googleId", "role"}
+	postPatchFields      = []string{"text", "images"}
+	messagePatchFields   = []string{"img"}
+	noticePatchFields    = []string{"read"}
+)
+
+// Читает merge patch из JSON-тела или из multipart-формы (тогда поля —
+// строки, а файлы обрабатывает сам обработчик). form — patch из формы.
+func readMergePatch(r *http.Request) (patch map[string]interface{}, form bool, err error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(10 << 20); err != nil {
+			return nil, true, err
+		}
+		patch := map[string]interface{}{}
+		for name, values := range r.MultipartForm.Value {
+			if len(values) > 0 {
+				patch[name] = values[0]
+			}
+		}
+		return patch, true, nil
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		return nil, false, err
+	}
+	if patch == nil {
+		return nil, false, errors.New("patch must be an object")
+	}
+	return patch, false, nil
+}
+
+// Приводит строки из формы к типу поля в doc: "true" для bool, "1" для чисел
+func coerceFormValues(doc, patch map[string]interface{}) error {
+	for key, value := range patch {
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		switch doc[key].(type) {
+		case bool:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return fmt.Errorf("%s must be a boolean", key)
+			}
+			patch[key] = b
+		case float64:
+			n, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return fmt.Errorf("%s must be a number", key)
+			}
+			patch[key] = n
+		}
+	}
+	return nil
+}
+
+// Применяет patch к doc: null удаляет поле, объект сливается рекурсивно,
+// любое другое значение заменяет поле целиком
+func mergeObjects(doc, patch map[string]interface{}) {
+	for key, value := range patch {
+		if value == nil {
+			delete(doc, key)
+			continue
+		}
+		if p, ok := value.(map[string]interface{}); ok {
+			d, ok := doc[key].(map[string]interface{})
+			if !ok {
+				d = map[string]interface{}{}
+			}
+			mergeObjects(d, p)
+			doc[key] = d
+			continue
+		}
+		doc[key] = value
+	}
+}
+
+// Поля с json:"-" в документ не попадают — переносим их из исходной структуры
+func keepHiddenFields[T any](merged, target *T) {
+	dst, src := reflect.ValueOf(merged).Elem(), reflect.ValueOf(target).Elem()
+	if dst.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < dst.NumField(); i++ {
+		if field := dst.Type().Field(i); field.IsExported() && field.Tag.Get("json") == "-" {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+}
+
+// Читает merge patch из запроса и применяет его к target, если в нём только
+// поля из allowed, а результат проходит validate (nil — без проверки).
+// false — ответ уже записан: 400 для некорректного тела, 422 для
+// запрещённых полей и невалидного результата.
+func applyMergePatch[T any](w http.ResponseWriter, r *http.Request, target *T, validate func(T) string, allowed ...string) bool {
+	patch, form, err := readMergePatch(r)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return false
+	}
+
+	permitted := make(map[string]bool, len(allowed))
+	for _, field := range allowed {
+		permitted[field] = true
+	}
+	var forbidden []string
+	for field := range patch {
+		if !permitted[field] {
+			forbidden = append(forbidden, field)
+		}
+	}
+	if len(forbidden) > 0 {
+		sort.Strings(forbidden)
+		http.Error(w, "Fields cannot be changed: "+strings.Join(forbidden, ", "), http.StatusUnprocessableEntity)
+		return false
+	}
+
+	// Документ собирается заново через JSON: удалённые поля получают нулевые значения
+	data, err := json.Marshal(target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if form {
+		if err := coerceFormValues(doc, patch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return false
+		}
+	}
+	mergeObjects(doc, patch)
+	if data, err = json.Marshal(doc); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	var merged T
+	if err := json.Unmarshal(data, &merged); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return false
+	}
+	keepHiddenFields(&merged, target)
+	if validate != nil {
+		if msg := validate(merged); msg != "" {
+			http.Error(w, msg, http.StatusUnprocessableEntity)
+			return false
+		}
+	}
+	*target = merged
+	return true
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMergePatchUser(t *testing.T) {
+	api := newTestAPI(t)
+	alice, token := api.register("alice")
+	path := "/api/twitter/users/" + alice.ID.Hex()
+
+	tests := []struct {
+		name string
+		body interface{}
+		want int
+	}{
+		{"allowed field", map[string]string{"name": "Alice"}, http.StatusOK},
+		{"server-maintained posts", map[string]interface{}{"posts": []UserPost{{Post: "zzz", Author: "evil"}}}, http.StatusUnprocessableEntity},
+		{"server-maintained messages", map[string]interface{}{"messages": []UserMessage{{Author: "evil", MessagesID: "x"}}}, http.StatusUnprocessableEntity},
+		{"role", map[string]string{"role": roleAdmin}, http.StatusUnprocessableEntity},
+		{"null name", map[string]interface{}{"name": nil}, http.StatusUnprocessableEntity},
+		{"empty name", map[string]string{"name": ""}, http.StatusUnprocessableEntity},
+		{"wrong type", map[string]int{"name": 5}, http.StatusBadRequest},
+		{"not an object", []int{1}, http.StatusBadRequest},
+		{"null avatar", map[string]interface{}{"avatar": nil}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := api.do("PATCH", path, token, tt.body); rec.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+
+	user, err := api.store.GetUser(context.Background(), alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "Alice" || user.Role != "" || len(user.Posts) != 0 || len(user.Messages) != 0 {
+		t.Errorf("stored user = %+v", user)
+	}
+}
+
+func TestMergePatchPost(t *testing.T) {
+	api := newTestAPI(t)
+	_, aliceToken := api.register("alice")
+	_, bobToken := api.register("bob")
+	id := api.createPost(aliceToken, "hello #go")
+	path := "/api/twitter/posts/" + id.Hex()
+	api.expect(http.StatusCreated, "POST", path+"/like", bobToken, nil)
+
+	api.expect(http.StatusUnprocessableEntity, "PATCH", path, aliceToken, map[string]interface{}{"text": nil})
+	api.expect(http.StatusUnprocessableEntity, "PATCH", path, aliceToken, map[string]int{"likes": 0})
+
+	var post Post
+	decodeJSON(t, api.expect(http.StatusOK, "PATCH", path, aliceToken, map[string]string{"text": "bye #rust"}), &post)
+	if post.Text != "bye #rust" || post.Likes != 1 || len(post.Hashtags) != 1 || post.Hashtags[0] != "rust" {
+		t.Errorf("patched post = %+v", post)
+	}
+}
+
+func TestMergePatchNoticeForm(t *testing.T) {
+	api := newTestAPI(t)
+	alice, aliceToken := api.register("alice")
+	_, bobToken := api.register("bob")
+
+	var notice Notice
+	decodeJSON(t, api.expect(http.StatusCreated, "POST", "/api/twitter/notices", bobToken,
+		map[string]string{"user": alice.ID.Hex(), "type": "like", "post": "p"}), &notice)
+
+	patchForm := func(values map[string]string) *httptest.ResponseRecorder {
+		return api.doForm("PATCH", "/api/twitter/notices/"+notice.ID.Hex(), aliceToken, values, nil)
+	}
+
+	if rec := patchForm(map[string]string{"read": "yes please"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid bool: status %d, want 400: %s", rec.Code, rec.Body.String())
+	}
+	rec := patchForm(map[string]string{"read": "true"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	decodeJSON(t, rec.Body.Bytes(), &notice)
+	if !notice.Read {
+		t.Error("notice is not marked read")
+	}
+}
+
+func TestServerMaintainsUserLists(t *testing.T) {
+	api := newTestAPI(t)
+	alice, aliceToken := api.register("alice")
+	bob, _ := api.register("bob")
+	ctx := context.Background()
+
+	post := api.createPost(aliceToken, "hi")
+	user, _ := api.store.GetUser(ctx, alice.ID)
+	if len(user.Posts) != 1 || user.Posts[0].Post != post.Hex() {
+		t.Errorf("posts after create = %+v", user.Posts)
+	}
+	api.expect(http.StatusOK, "DELETE", "/api/twitter/posts/"+post.Hex(), aliceToken, nil)
+	user, _ = api.store.GetUser(ctx, alice.ID)
+	if len(user.Posts) != 0 {
+		t.Errorf("posts after delete = %+v", user.Posts)
+	}
+
+	api.expect(http.StatusCreated, "POST", "/api/twitter/messages", aliceToken, map[string]string{"id": "chat", "receiver": bob.ID.Hex()})
+	api.expect(http.StatusCreated, "POST", "/api/twitter/messages", aliceToken, map[string]string{"id": "chat", "receiver": bob.ID.Hex()})
+	user, _ = api.store.GetUser(ctx, alice.ID)
+	if len(user.Messages) != 1 || user.Messages[0] != (UserMessage{Author: bob.ID.Hex(), MessagesID: "chat"}) {
+		t.Errorf("sender messages = %+v", user.Messages)
+	}
+	user, _ = api.store.GetUser(ctx, bob.ID)
+	if len(user.Messages) != 1 || user.Messages[0] != (UserMessage{Author: alice.ID.Hex(), MessagesID: "chat"}) {
+		t.Errorf("receiver messages = %+v", user.Messages)
+	}
+}
+
+// Обработчик пишет документ, прочитанный до патча: параллельные лайки,
+// комментарии и списки пользователя не должны затираться
+func TestUpdateKeepsConcurrentChanges(t *testing.T) {
+	api := newTestAPI(t)
+	alice, aliceToken := api.register("alice")
+	bob, _ := api.register("bob")
+	ctx := context.Background()
+	id := api.createPost(aliceToken, "hello")
+
+	stale, err := api.store.GetPost(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.store.LikePost(ctx, bob, stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.store.AddComment(ctx, id, Comment{ID: primitive.NewObjectID(), Text: "hi", Author: bob.ID.Hex()}); err != nil {
+		t.Fatal(err)
+	}
+	stale.Text = "edited"
+	post, err := api.store.UpdatePost(ctx, id, stale, postPatchFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.Text != "edited" || post.Likes != 1 || len(post.Comments) != 1 {
+		t.Errorf("post after stale update = %+v", post)
+	}
+
+	staleUser, _ := api.store.GetUser(ctx, alice.ID)
+	api.createPost(aliceToken, "second")
+	staleUser.Name = "Alice"
+	user, err := api.store.UpdateUser(ctx, alice.ID, staleUser, userPatchFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "Alice" || len(user.Posts) != 2 {
+		t.Errorf("user after stale update = %+v", user)
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	collectionSchemaMigrations = "schema_migrations"
+	collectionMigrationLock    = "migration_lock"
+
+	// Блокировка, которую не продлевали дольше этого, считается брошенной
+	// (процесс упал). Пока миграция идёт, withLock продлевает её.
+	migrationLockTTL           = 2 * time.Minute
+	migrationLockRenewInterval = migrationLockTTL / 4
+)
+
+var (
+	errMigrationLocked   = errors.New("another migration is in progress")
+	errMigrationLockLost = errors.New("migration lock lost")
+)
+
+// Миграция схемы. Down == nil — миграция необратима.
+type migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+	Down    func(ctx context.Context, db *mongo.Database) error
+}
+
+// Запись о применённой миграции в schema_migrations
+type appliedMigration struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"appliedAt"`
+}
+
+type migrationLock struct {
+	ID        string    `bson:"_id"`
+	Owner     string    `bson:"owner"`
+	LockedAt  time.Time `bson:"lockedAt"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+type migrator struct {
+	db         *mongo.Database
+	migrations []migration
+	dryRun     bool
+	owner      string
+}
+
+// migrate up|down|status [--dry-run]
+//
+//	up     — применить все ожидающие миграции по возрастанию версии
+//	down   — откатить последнюю применённую миграцию
+//	status — показать применённые и ожидающие миграции
+//
+// С --dry-run up и down только печатают, что было бы сделано.
+func runMigrate(ctx context.Context, db *mongo.Database, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down|status [--dry-run]")
+	}
+
+	fs := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "print the plan without applying it")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	host, _ := os.Hostname()
+	m := &migrator{
+		db:         db,
+		migrations: migrations,
+		dryRun:     *dryRun,
+		owner:      fmt.Sprintf("%s:%d", host, os.Getpid()),
+	}
+
+	switch args[0] {
+	case "up":
+		return m.withLock(ctx, m.up)
+	case "down":
+		return m.withLock(ctx, m.down)
+	case "status":
+		return m.status(ctx)
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+func (m *migrator) applied(ctx context.Context) (map[int]appliedMigration, error) {
+	items, err := findAll[appliedMigration](ctx, m.db.Collection(collectionSchemaMigrations), bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int]appliedMigration, len(items))
+	for _, a := range items {
+		applied[a.Version] = a
+	}
+	return applied, nil
+}
+
+func (m *migrator) up(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		pending++
+		if m.dryRun {
+			log.Printf("would apply %d_%s", mig.Version, mig.Name)
+			continue
+		}
+
+		log.Printf("applying %d_%s", mig.Version, mig.Name)
+		if err := mig.Up(ctx, m.db); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		record := appliedMigration{Version: mig.Version, Name: mig.Name, AppliedAt: time.Now()}
+		if _, err := m.db.Collection(collectionSchemaMigrations).InsertOne(ctx, record); err != nil {
+			return err
+		}
+	}
+
+	if pending == 0 {
+		log.Println("schema is up to date")
+	}
+	return nil
+}
+
+func (m *migrator) down(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		if mig.Down == nil {
+			return fmt.Errorf("migration %d_%s is irreversible", mig.Version, mig.Name)
+		}
+		if m.dryRun {
+			log.Printf("would roll back %d_%s", mig.Version, mig.Name)
+			return nil
+		}
+
+		log.Printf("rolling back %d_%s", mig.Version, mig.Name)
+		if err := mig.Down(ctx, m.db); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		_, err := m.db.Collection(collectionSchemaMigrations).DeleteOne(ctx, bson.M{"_id": mig.Version})
+		return err
+	}
+
+	log.Println("no migrations to roll back")
+	return nil
+}
+
+func (m *migrator) status(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	for _, mig := range m.migrations {
+		if a, ok := applied[mig.Version]; ok {
+			fmt.Printf("%4d  %-40s applied %s\n", mig.Version, mig.Name, a.AppliedAt.Format(time.RFC3339))
+		} else {
+			fmt.Printf("%4d  %-40s pending\n", mig.Version, mig.Name)
+		}
+	}
+	return nil
+}
+
+// Выполняет fn, держа блокировку в migration_lock. Один документ с
+// фиксированным _id: вторая вставка падает с duplicate key, пока первый
+// процесс не снимет блокировку или она не истечёт. Если блокировку
+// потеряли, контекст fn отменяется.
+func (m *migrator) withLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	locks := m.db.Collection(collectionMigrationLock)
+	now := time.Now()
+	lock := migrationLock{ID: "migrate", Owner: m.owner, LockedAt: now, ExpiresAt: now.Add(migrationLockTTL)}
+
+	_, err := locks.InsertOne(ctx, lock)
+	if mongo.IsDuplicateKeyError(err) {
+		// Перехватываем только истёкшую блокировку
+		result, err := locks.ReplaceOne(ctx, bson.M{"_id": lock.ID, "expiresAt": bson.M{"$lt": now}}, lock)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			var holder migrationLock
+			if err := locks.FindOne(ctx, bson.M{"_id": lock.ID}).Decode(&holder); err == nil {
+				return fmt.Errorf("%w (held by %s since %s)", errMigrationLocked, holder.Owner, holder.LockedAt.Format(time.RFC3339))
+			}
+			return errMigrationLocked
+		}
+	} else if err != nil {
+		return err
+	}
+
+	defer func() {
+		// Снимаем блокировку и при ошибке миграции; контекст может быть уже отменён
+		release, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if _, err := locks.DeleteOne(release, bson.M{"_id": lock.ID, "owner": m.owner}); err != nil {
+			log.Printf("Error releasing migration lock: %v", err)
+		}
+	}()
+
+	renew := func(ctx context.Context, expiresAt time.Time) error {
+		result, err := locks.UpdateOne(ctx, bson.M{"_id": lock.ID, "owner": m.owner}, bson.M{"$set": bson.M{"expiresAt": expiresAt}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return fmt.Errorf("%w: taken over by another process", errMigrationLockLost)
+		}
+		return nil
+	}
+
+	runCtx, abort := context.WithCancelCause(ctx)
+	defer abort(nil)
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		if err := keepLock(heartbeatCtx, renew, migrationLockRenewInterval, migrationLockTTL); err != nil {
+			abort(err)
+		}
+	}()
+
+	err = fn(runCtx)
+	stopHeartbeat()
+	<-stopped
+	if cause := context.Cause(runCtx); err != nil && errors.Is(cause, errMigrationLockLost) {
+		return fmt.Errorf("%w (migration aborted: %v)", cause, err)
+	}
+	return err
+}
+
+// Продлевает блокировку каждые interval, пока ctx не отменён. Ошибку
+// возвращает, если блокировку перехватили или не удалось продлить её
+// до истечения.
+func keepLock(ctx context.Context, renew func(ctx context.Context, expiresAt time.Time) error, interval, ttl time.Duration) error {
+	expires := time.Now().Add(ttl)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		next := time.Now().Add(ttl)
+		renewCtx, cancel := context.WithTimeout(ctx, interval)
+		err := renew(renewCtx, next)
+		cancel()
+		switch {
+		case err == nil:
+			expires = next
+		case errors.Is(err, errMigrationLockLost):
+			return err
+		case ctx.Err() != nil:
+			return nil
+		case time.Until(expires) < interval:
+			return fmt.Errorf("%w: %v", errMigrationLockLost, err)
+		default:
+			log.Printf("Error renewing migration lock: %v", err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeepLock(t *testing.T) {
+	const (
+		interval = 5 * time.Millisecond
+		ttl      = 4 * interval
+	)
+	errTransient := errors.New("connection reset")
+
+	tests := []struct {
+		name  string
+		renew func(call int) error
+		lost  bool
+	}{
+		{"renewed", func(int) error { return nil }, false},
+		{"transient failure", func(call int) error {
+			if call == 1 {
+				return errTransient
+			}
+			return nil
+		}, false},
+		{"taken over", func(call int) error {
+			if call == 2 {
+				return errMigrationLockLost
+			}
+			return nil
+		}, true},
+		{"renewal keeps failing", func(int) error { return errTransient }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			renew := func(ctx context.Context, expiresAt time.Time) error {
+				return tt.renew(int(calls.Add(1)))
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*ttl)
+			defer cancel()
+			err := keepLock(ctx, renew, interval, ttl)
+			if tt.lost != errors.Is(err, errMigrationLockLost) {
+				t.Fatalf("keepLock = %v, lost %v", err, tt.lost)
+			}
+			if tt.lost && ctx.Err() != nil {
+				t.Error("lost lock was reported only after the context ended")
+			}
+			if n := calls.Load(); n < 2 {
+				t.Errorf("renew called %d times", n)
+			}
+		})
+	}
+}
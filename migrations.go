@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Коды ошибок MongoDB
+const (
+	errCodeNamespaceNotFound = 26
+	errCodeIndexNotFound     = 27
+)
+
+// Миграции схемы по возрастанию версии. Версии не переиспользуются:
+// новая миграция всегда добавляется в конец списка.
+var migrations = []migration{
+	{Version: 1, Name: "normalize_embedded_arrays", Up: normalizeEmbeddedArrays},
+	{Version: 2, Name: "backfill_comment_ids", Up: backfillCommentIDs},
+	{Version: 3, Name: "convert_string_dates", Up: convertStringDates, Down: revertStringDates},
+	{Version: 4, Name: "extract_hashtags", Up: extractPostHashtags, Down: removePostHashtags},
+	{Version: 5, Name: "user_text_index_with_handle", Up: replaceUserTextIndex, Down: restoreUserTextIndex},
+}
+
+// Старые комментарии сохранены без _id и depth; без id их нельзя
+// редактировать, удалять и использовать как parentId.
+func backfillCommentIDs(ctx context.Context, db *mongo.Database) error {
+	posts := db.Collection(collectionPost)
+	cursor, err := posts.Find(ctx,
+		bson.M{"comments": bson.M{"$elemMatch": bson.M{"_id": bson.M{"$exists": false}}}},
+		options.Find().SetProjection(bson.M{"comments": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var post struct {
+			ID       primitive.ObjectID `bson:"_id"`
+			Comments []bson.M           `bson:"comments"`
+		}
+		if err := cursor.Decode(&post); err != nil {
+			return err
+		}
+
+		for _, c := range post.Comments {
+			if _, ok := c["_id"]; ok {
+				continue
+			}
+			created := time.Now()
+			if dt, ok := c["createDate"].(primitive.DateTime); ok {
+				created = dt.Time()
+			}
+			c["_id"] = objectIDAt(created)
+			if _, ok := c["depth"]; !ok {
+				c["depth"] = 0
+			}
+		}
+
+		if _, err := posts.UpdateOne(ctx, bson.M{"_id": post.ID}, bson.M{"$set": bson.M{"comments": post.Comments}}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// Поля, которые старые клиенты сохраняли строками RFC 3339
+var stringDateFields = map[string]string{
+	collectionUser:    "registrationDate",
+	collectionPost:    "createDate",
+	collectionChat:    "createDate",
+	collectionMessage: "createDate",
+}
+
+// Строковые даты становятся BSON date. Пустые, отсутствующие и
+// неразборчивые значения заменяются временем создания документа из _id.
+// Разбор тот же, что при чтении (parseLegacyTime), поэтому документ
+// читается одинаково до и после миграции.
+func convertStringDates(ctx context.Context, db *mongo.Database) error {
+	for collection, field := range stringDateFields {
+		if err := convertStringDateField(ctx, db.Collection(collection), field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func convertStringDateField(ctx context.Context, coll *mongo.Collection, field string) error {
+	cursor, err := coll.Find(ctx,
+		bson.M{"$or": bson.A{
+			bson.M{field: bson.M{"$type": "string"}},
+			bson.M{field: nil},
+		}},
+		options.Find().SetProjection(bson.M{field: 1}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var id primitive.ObjectID
+		if err := cursor.Current.Lookup("_id").Unmarshal(&id); err != nil {
+			return err
+		}
+		var date Timestamp
+		if value, err := cursor.Current.LookupErr(field); err == nil {
+			if err := value.Unmarshal(&date); err != nil {
+				return err
+			}
+		}
+		if _, err := coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{field: date.orCreatedAt(id)}}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// Обратно в строки RFC 3339 (UTC, без долей секунды)
+func revertStringDates(ctx context.Context, db *mongo.Database) error {
+	for collection, field := range stringDateFields {
+		_, err := db.Collection(collection).UpdateMany(ctx,
+			bson.M{field: bson.M{"$type": "date"}},
+			mongo.Pipeline{{{Key: "$set", Value: bson.M{field: bson.M{"$dateToString": bson.M{
+				"date":   "$" + field,
+				"format": "%Y-%m-%dT%H:%M:%SZ",
+			}}}}}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Хештеги для постов, созданных до появления поля hashtags
+func extractPostHashtags(ctx context.Context, db *mongo.Database) error {
+	posts := db.Collection(collectionPost)
+	cursor, err := posts.Find(ctx,
+		bson.M{"hashtags": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{"text": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var post struct {
+			ID   primitive.ObjectID `bson:"_id"`
+			Text string             `bson:"text"`
+		}
+		if err := cursor.Decode(&post); err != nil {
+			return err
+		}
+		if _, err := posts.UpdateOne(ctx, bson.M{"_id": post.ID}, bson.M{"$set": bson.M{"hashtags": extractHashtags(post.Text)}}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func removePostHashtags(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(collectionPost).UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"hashtags": ""}})
+	return err
+}
+
+// Текстовый индекс в коллекции может быть только один: старый name_text
+// не даёт создать name_text_handle_text
+func replaceUserTextIndex(ctx context.Context, db *mongo.Database) error {
+	if err := dropIndex(ctx, db.Collection(collectionUser), "name_text"); err != nil {
+		return err
+	}
+	return ensureIndexes(ctx, db, collectionUser)
+}
+
+func restoreUserTextIndex(ctx context.Context, db *mongo.Database) error {
+	users := db.Collection(collectionUser)
+	if err := dropIndex(ctx, users, "name_text_handle_text"); err != nil {
+		return err
+	}
+	_, err := users.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: "text"}},
+		Options: options.Index().SetName("name_text"),
+	})
+	return err
+}
+
+// Удаляет индекс; если его или коллекции нет — ничего не делает
+func dropIndex(ctx context.Context, coll *mongo.Collection, name string) error {
+	_, err := coll.Indexes().DropOne(ctx, name)
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && (cmdErr.Code == errCodeNamespaceNotFound || cmdErr.Code == errCodeIndexNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Старый формат: связи хранились массивами внутри users и posts.
+// Эти типы нужны только для чтения документов при миграции.
+type legacyUser struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Subscriptions []Subscription     `bson:"subscriptions"`
+	Subscribers   []Subscriber       `bson:"subscribers"`
+	LikesPosts    []LikePost         `bson:"likesPosts"`
+	Bookmarks     []Bookmark         `bson:"bookmarks"`
+	Reposts       []Repost           `bson:"reposts"`
+}
+
+type legacyPost struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Author    string             `bson:"author"`
+	Reposts   []PostRepost       `bson:"reposts"`
+	Bookmarks []PostBookmark     `bson:"bookmarks"`
+}
+
+// Элемент Post.Bookmarks; Author — пользователь, добавивший закладку
+type PostBookmark struct {
+	PostID     string    `json:"post_id" bson:"post_id"`
+	Author     string    `json:"author" bson:"author"`
+	CreateDate Timestamp `json:"createDate" bson:"createDate"`
+}
+
+var legacyUserFields = []string{"subscriptions", "subscribers", "likesPosts", "bookmarks", "reposts"}
+var legacyPostFields = []string{"reposts", "bookmarks"}
+
+// Переносит связи из массивов users и posts в отдельные коллекции.
+// Повторный запуск безопасен: связи вставляются через upsert.
+func normalizeEmbeddedArrays(ctx context.Context, db *mongo.Database) error {
+	// Уникальные индексы, на которые опираются upsert ниже
+	if err := ensureIndexes(ctx, db, collectionFollow, collectionLike, collectionBookmark, collectionPost); err != nil {
+		return err
+	}
+
+	n := &normalizer{db: db}
+	if err := n.users(ctx); err != nil {
+		return err
+	}
+	if err := n.posts(ctx); err != nil {
+		return err
+	}
+	if err := n.recountReposts(ctx); err != nil {
+		return err
+	}
+	if err := n.recountLikes(ctx); err != nil {
+		return err
+	}
+
+	log.Printf("Normalized %d users and %d posts: %d follows, %d likes, %d bookmarks, %d reposts",
+		n.userCount, n.postCount, n.follows, n.likes, n.bookmarks, n.reposts)
+	return nil
+}
+
+type normalizer struct {
+	db *mongo.Database
+
+	userCount, postCount               int
+	follows, likes, bookmarks, reposts int
+}
+
+// Вставляет doc, если документа по filter ещё нет
+func (n *normalizer) upsert(ctx context.Context, collection string, filter bson.M, doc interface{}) (bool, error) {
+	result, err := n.db.Collection(collection).UpdateOne(ctx, filter,
+		bson.M{"$setOnInsert": doc},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount > 0, nil
+}
+
+func (n *normalizer) users(ctx context.Context) error {
+	users := n.db.Collection(collectionUser)
+	cursor, err := users.Find(ctx, legacyFilter(legacyUserFields))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var u legacyUser
+		if err := cursor.Decode(&u); err != nil {
+			return err
+		}
+		id := u.ID.Hex()
+
+		for _, sub := range u.Subscriptions {
+			if err := n.follow(ctx, id, sub.User); err != nil {
+				return err
+			}
+		}
+		for _, sub := range u.Subscribers {
+			if err := n.follow(ctx, sub.User, id); err != nil {
+				return err
+			}
+		}
+		for _, like := range u.LikesPosts {
+			if !like.State {
+				continue
+			}
+			created, err := n.upsert(ctx, collectionLike, bson.M{"user": id, "post": like.Post},
+				LikePost{ID: primitive.NewObjectID(), User: id, Post: like.Post, Author: like.Author, State: true, CreateDate: timestampNow()})
+			if err != nil {
+				return err
+			}
+			n.likes += countIf(created)
+		}
+		for _, b := range u.Bookmarks {
+			if !b.State {
+				continue
+			}
+			if err := n.bookmark(ctx, id, b.Post, b.Author, b.CreateDate); err != nil {
+				return err
+			}
+		}
+		for _, r := range u.Reposts {
+			if !r.State {
+				continue
+			}
+			if err := n.repost(ctx, id, r.Post, Timestamp{}); err != nil {
+				return err
+			}
+		}
+
+		if _, err := users.UpdateOne(ctx, bson.M{"_id": u.ID}, bson.M{"$unset": unsetFields(legacyUserFields)}); err != nil {
+			return err
+		}
+		n.userCount++
+	}
+	return cursor.Err()
+}
+
+func (n *normalizer) posts(ctx context.Context) error {
+	posts := n.db.Collection(collectionPost)
+	cursor, err := posts.Find(ctx, legacyFilter(legacyPostFields))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var p legacyPost
+		if err := cursor.Decode(&p); err != nil {
+			return err
+		}
+		id := p.ID.Hex()
+
+		for _, r := range p.Reposts {
+			if err := n.repost(ctx, r.Author, id, r.CreateDate); err != nil {
+				return err
+			}
+		}
+		for _, b := range p.Bookmarks {
+			if err := n.bookmark(ctx, b.Author, id, p.Author, b.CreateDate); err != nil {
+				return err
+			}
+		}
+
+		if _, err := posts.UpdateOne(ctx, bson.M{"_id": p.ID}, bson.M{"$unset": unsetFields(legacyPostFields)}); err != nil {
+			return err
+		}
+		n.postCount++
+	}
+	return cursor.Err()
+}
+
+func (n *normalizer) follow(ctx context.Context, follower, followee string) error {
+	if follower == "" || followee == "" || follower == followee {
+		return nil
+	}
+	created, err := n.upsert(ctx, collectionFollow, bson.M{"follower": follower, "followee": followee},
+		Follow{ID: primitive.NewObjectID(), Follower: follower, Followee: followee, CreateDate: timestampNow()})
+	n.follows += countIf(created)
+	return err
+}
+
+func (n *normalizer) bookmark(ctx context.Context, user, post, author string, date Timestamp) error {
+	if user == "" || post == "" {
+		return nil
+	}
+	if date.IsZero() {
+		date = timestampNow()
+	}
+	created, err := n.upsert(ctx, collectionBookmark, bson.M{"user": user, "post": post},
+		Bookmark{ID: objectIDAt(date.Time), User: user, Post: post, Author: author, State: true, CreateDate: date})
+	n.bookmarks += countIf(created)
+	return err
+}
+
+// Репост из массива становится постом с RepostOf; репосты удалённых постов пропускаются
+func (n *normalizer) repost(ctx context.Context, author, original string, date Timestamp) error {
+	originalID, err := primitive.ObjectIDFromHex(original)
+	if err != nil || author == "" {
+		return nil
+	}
+	exists, err := n.db.Collection(collectionPost).CountDocuments(ctx, bson.M{"_id": originalID})
+	if err != nil || exists == 0 {
+		return err
+	}
+
+	if date.IsZero() {
+		date = timestampNow()
+	}
+	inserted, err := n.upsert(ctx, collectionPost, bson.M{"author": author, "repostOf": original}, Post{
+		ID:         objectIDAt(date.Time),
+		Author:     author,
+		CreateDate: date,
+		Comments:   []Comment{},
+		RepostOf:   original,
+	})
+	n.reposts += countIf(inserted)
+	return err
+}
+
+// Пересчитывает repostCount у всех оригиналов по постам-репостам
+func (n *normalizer) recountReposts(ctx context.Context) error {
+	posts := n.db.Collection(collectionPost)
+	cursor, err := posts.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"repostOf": bson.M{"$exists": true}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$repostOf", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var row struct {
+			ID    string `bson:"_id"`
+			Count int    `bson:"count"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return err
+		}
+		id, err := primitive.ObjectIDFromHex(row.ID)
+		if err != nil {
+			continue
+		}
+		if _, err := posts.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"repostCount": row.Count}}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// Пересчитывает likes у всех постов по коллекции лайков;
+// посты без лайков получают 0
+func (n *normalizer) recountLikes(ctx context.Context) error {
+	posts := n.db.Collection(collectionPost)
+	cursor, err := n.db.Collection(collectionLike).Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$post", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	liked := []primitive.ObjectID{}
+	for cursor.Next(ctx) {
+		var row struct {
+			ID    string `bson:"_id"`
+			Count int    `bson:"count"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return err
+		}
+		id, err := primitive.ObjectIDFromHex(row.ID)
+		if err != nil {
+			continue
+		}
+		liked = append(liked, id)
+		if _, err := posts.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"likes": row.Count}}); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	_, err = posts.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$nin": liked}, "likes": bson.M{"$ne": 0}},
+		bson.M{"$set": bson.M{"likes": 0}},
+	)
+	return err
+}
+
+func legacyFilter(fields []string) bson.M {
+	or := bson.A{}
+	for _, f := range fields {
+		or = append(or, bson.M{f: bson.M{"$exists": true}})
+	}
+	return bson.M{"$or": or}
+}
+
+func unsetFields(fields []string) bson.M {
+	unset := bson.M{}
+	for _, f := range fields {
+		unset[f] = ""
+	}
+	return unset
+}
+
+// ObjectID со временем создания t, чтобы перенесённые связи встали
+// в списки на свои места
+func objectIDAt(t time.Time) primitive.ObjectID {
+	id := primitive.NewObjectID()
+	binary.BigEndian.PutUint32(id[0:4], uint32(t.Unix()))
+	return id
+}
+
+func countIf(created bool) int {
+	if created {
+		return 1
+	}
+	return 0
+}
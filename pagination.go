@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// Page — параметры курсорной пагинации. Списки идут от новых к старым
+// по _id; After — _id последнего элемента предыдущей страницы.
+type Page struct {
+	Limit int
+	After primitive.ObjectID
+}
+
+// Хранилища возвращают до Limit+1 элементов: лишний элемент означает,
+// что есть следующая страница.
+func (p Page) fetchLimit() int {
+	return p.Limit + 1
+}
+
+type pageResponse[T any] struct {
+	Data       []T     `json:"data"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+func encodeCursor(id primitive.ObjectID) string {
+	return base64.RawURLEncoding.EncodeToString(id[:])
+}
+
+func decodeCursor(cursor string) (primitive.ObjectID, error) {
+	var id primitive.ObjectID
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(b) != len(id) {
+		return id, errInvalidCursor
+	}
+	copy(id[:], b)
+	return id, nil
+}
+
+// Читает ?limit=&cursor= из запроса; limit больше maxPageSize урезается
+func parsePage(r *http.Request) (Page, error) {
+	page := Page{Limit: defaultPageSize}
+
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return page, errors.New("invalid limit")
+		}
+		page.Limit = min(limit, maxPageSize)
+	}
+
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		after, err := decodeCursor(v)
+		if err != nil {
+			return page, err
+		}
+		page.After = after
+	}
+	return page, nil
+}
+
+// Страница из уже загруженного списка, в том же порядке, что и у хранилищ
+func pageItems[T any](items []T, page Page, idOf func(T) primitive.ObjectID) []T {
+	sorted := make([]T, 0, len(items))
+	for _, item := range items {
+		id := idOf(item)
+		if page.After.IsZero() || bytes.Compare(id[:], page.After[:]) < 0 {
+			sorted = append(sorted, item)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := idOf(sorted[i]), idOf(sorted[j])
+		return bytes.Compare(a[:], b[:]) > 0
+	})
+	if len(sorted) > page.fetchLimit() {
+		sorted = sorted[:page.fetchLimit()]
+	}
+	return sorted
+}
+
+// Пишет страницу в ответ, отрезая лишний элемент и выставляя next_cursor
+func writePage[T any](w http.ResponseWriter, items []T, page Page, idOf func(T) primitive.ObjectID) {
+	resp := pageResponse[T]{Data: items}
+	if len(items) > page.Limit {
+		resp.Data = items[:page.Limit]
+		next := encodeCursor(idOf(resp.Data[page.Limit-1]))
+		resp.NextCursor = &next
+	}
+	if resp.Data == nil {
+		resp.Data = []T{}
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Страница из списка, упорядоченного не по _id (например, по релевантности):
+// элементы, идущие после page.After, до Limit+1 штук
+func pageAfter[T any](items []T, page Page, idOf func(T) primitive.ObjectID) []T {
+	start := 0
+	if !page.After.IsZero() {
+		start = len(items)
+		for i, item := range items {
+			if idOf(item) == page.After {
+				start = i + 1
+				break
+			}
+		}
+	}
+	items = items[start:]
+	if len(items) > page.fetchLimit() {
+		items = items[:page.fetchLimit()]
+	}
+	return items
+}
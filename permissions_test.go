@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// Ресурс с владельцем: create создаёт документ owner и возвращает его путь
+type ownedResource struct {
+	name   string
+	create func(api *testAPI, owner, other User, ownerToken, otherToken string) string
+	patch  interface{}
+}
+
+var ownedResources = []ownedResource{
+	{
+		name: "posts",
+		create: func(api *testAPI, owner, other User, ownerToken, otherToken string) string {
+			return "/api/twitter/posts/" + api.createPost(ownerToken, "hello").Hex()
+		},
+		patch: map[string]string{"text": "edited"},
+	},
+	{
+		name: "messages",
+		create: func(api *testAPI, owner, other User, ownerToken, otherToken string) string {
+			var message Message
+			decodeJSON(api.t, api.expect(http.StatusCreated, "POST", "/api/twitter/messages", ownerToken,
+				map[string]string{"id": "chat", "receiver": other.ID.Hex()}), &message)
+			return "/api/twitter/messages/" + message.ID.Hex()
+		},
+		patch: map[string]string{"img": "https://example.com/a.png"},
+	},
+	{
+		// Уведомлением владеет получатель, а не отправитель
+		name: "notices",
+		create: func(api *testAPI, owner, other User, ownerToken, otherToken string) string {
+			var notice Notice
+			decodeJSON(api.t, api.expect(http.StatusCreated, "POST", "/api/twitter/notices", otherToken,
+				map[string]string{"user": owner.ID.Hex(), "type": "like", "post": "p"}), &notice)
+			return "/api/twitter/notices/" + notice.ID.Hex()
+		},
+		patch: map[string]bool{"read": true},
+	},
+	{
+		name: "users",
+		create: func(api *testAPI, owner, other User, ownerToken, otherToken string) string {
+			return "/api/twitter/users/" + owner.ID.Hex()
+		},
+		patch: map[string]string{"name": "renamed"},
+	},
+}
+
+func TestOwnerPermissions(t *testing.T) {
+	callers := []struct {
+		name string
+		want int
+	}{
+		{"owner", http.StatusOK},
+		{"other", http.StatusForbidden},
+		{"admin", http.StatusOK},
+		{"anonymous", http.StatusUnauthorized},
+	}
+
+	for _, res := range ownedResources {
+		for _, method := range []string{"PUT", "PATCH", "DELETE"} {
+			for _, caller := range callers {
+				t.Run(fmt.Sprintf("%s/%s/%s", res.name, method, caller.name), func(t *testing.T) {
+					// Своё API на каждый случай: DELETE удаляет документ и пользователей
+					api := newTestAPI(t)
+					_, adminToken := api.registerAdmin("admin")
+					owner, ownerToken := api.register("owner")
+					other, otherToken := api.register("other")
+					path := res.create(api, owner, other, ownerToken, otherToken)
+
+					token := map[string]string{"owner": ownerToken, "other": otherToken, "admin": adminToken}[caller.name]
+					var body interface{}
+					if method != "DELETE" {
+						body = res.patch
+					}
+					if rec := api.do(method, path, token, body); rec.Code != caller.want {
+						t.Errorf("status %d, want %d: %s", rec.Code, caller.want, rec.Body.String())
+					}
+				})
+			}
+		}
+	}
+}
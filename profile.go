@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxBioLength      = 160
+	maxLocationLength = 30
+	maxWebsiteLength  = 100
+
+	// Кому виден день рождения
+	birthdayPublic   = "public"
+	birthdayMonthDay = "month_day" // только день и месяц
+	birthdayPrivate  = "private"
+)
+
+// Профиль пользователя; меняется через PATCH /users/{id}/profile
+type UserProfile struct {
+	Bio      string `json:"bio" bson:"bio,omitempty"`
+	Banner   string `json:"banner" bson:"banner,omitempty"`
+	Location string `json:"location" bson:"location,omitempty"`
+	Website  string `json:"website" bson:"website,omitempty"`
+	// YYYY-MM-DD
+	Birthday           string `json:"birthday,omitempty" bson:"birthday,omitempty"`
+	BirthdayVisibility string `json:"birthdayVisibility" bson:"birthdayVisibility,omitempty"`
+	PinnedPost         string `json:"pinnedPost,omitempty" bson:"pinnedPost,omitempty"`
+}
+
+// Публичный профиль: без email, googleId, роли и списков связей
+type PublicProfile struct {
+	ID               primitive.ObjectID `json:"_id"`
+	Handle           string             `json:"handle,omitempty"`
+	Name             string             `json:"name"`
+	Avatar           string             `json:"avatar"`
+	RegistrationDate Timestamp          `json:"registrationDate"`
+	Bio              string             `json:"bio"`
+	Banner           string             `json:"banner"`
+	Location         string             `json:"location"`
+	Website          string             `json:"website"`
+	// YYYY-MM-DD или MM-DD, в зависимости от видимости
+	Birthday   string `json:"birthday,omitempty"`
+	PinnedPost string `json:"pinnedPost,omitempty"`
+}
+
+func publicProfile(user User) PublicProfile {
+	return PublicProfile{
+		ID:               user.ID,
+		Handle:           user.Handle,
+		Name:             user.Name,
+		Avatar:           user.Avatar,
+		RegistrationDate: user.RegistrationDate,
+		Bio:              user.Profile.Bio,
+		Banner:           user.Profile.Banner,
+		Location:         user.Profile.Location,
+		Website:          user.Profile.Website,
+		Birthday:         visibleBirthday(user.Profile),
+		PinnedPost:       user.Profile.PinnedPost,
+	}
+}
+
+// День рождения в том виде, в каком его видят другие; "" — скрыт
+func visibleBirthday(profile UserProfile) string {
+	switch profile.BirthdayVisibility {
+	case birthdayPublic:
+		return profile.Birthday
+	case birthdayMonthDay:
+		if len(profile.Birthday) == len("2006-01-02") {
+			return profile.Birthday[5:]
+		}
+	}
+	return ""
+}
+
+// Поля PATCH: отсутствующее поле не меняется, пустая строка его очищает
+type profilePatch struct {
+	Bio                *string `json:"bio"`
+	Banner             *string `json:"banner"`
+	Location           *string `json:"location"`
+	Website            *string `json:"website"`
+	Birthday           *string `json:"birthday"`
+	BirthdayVisibility *string `json:"birthdayVisibility"`
+	PinnedPost         *string `json:"pinnedPost"`
+}
+
+// Читает patch из JSON или из multipart-формы (с файлом banner)
+func readProfilePatch(r *http.Request) (profilePatch, error) {
+	var patch profilePatch
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		err := json.NewDecoder(r.Body).Decode(&patch)
+		return patch, err
+	}
+
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		return patch, err
+	}
+	for name, dst := range map[string]**string{
+		"bio":                &patch.Bio,
+		"banner":             &patch.Banner,
+		"location":           &patch.Location,
+		"website":            &patch.Website,
+		"birthday":           &patch.Birthday,
+		"birthdayVisibility": &patch.BirthdayVisibility,
+		"pinnedPost":         &patch.PinnedPost,
+	} {
+		if values, ok := r.MultipartForm.Value[name]; ok && len(values) > 0 {
+			v := values[0]
+			*dst = &v
+		}
+	}
+	return patch, nil
+}
+
+func validateWebsite(website string) string {
+	if utf8.RuneCountInString(website) > maxWebsiteLength {
+		return "Website is too long"
+	}
+	u, err := url.Parse(website)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "Website must be an http(s) URL"
+	}
+	return ""
+}
+
+func validateBirthday(birthday string) string {
+	t, err := time.Parse("2006-01-02", birthday)
+	if err != nil {
+		return "Birthday must be YYYY-MM-DD"
+	}
+	if t.Year() < 1900 || t.After(time.Now()) {
+		return "Invalid birthday"
+	}
+	return ""
+}
+
+// Применяет patch к профилю; сообщение об ошибке валидации или ""
+func (s *server) applyProfilePatch(ctx context.Context, user User, patch profilePatch) (UserProfile, string, error) {
+	profile := user.Profile
+	if patch.Bio != nil {
+		profile.Bio = strings.TrimSpace(*patch.Bio)
+		if utf8.RuneCountInString(profile.Bio) > maxBioLength {
+			return profile, "Bio is too long", nil
+		}
+	}
+	if patch.Location != nil {
+		profile.Location = strings.TrimSpace(*patch.Location)
+		if utf8.RuneCountInString(profile.Location) > maxLocationLength {
+			return profile, "Location is too long", nil
+		}
+	}
+	if patch.Website != nil {
+		profile.Website = strings.TrimSpace(*patch.Website)
+		if profile.Website != "" {
+			if msg := validateWebsite(profile.Website); msg != "" {
+				return profile, msg, nil
+			}
+		}
+	}
+	// Баннер загружается файлом; строкой его можно только убрать
+	if patch.Banner != nil {
+		if *patch.Banner != "" {
+			return profile, "Banner must be uploaded as a file", nil
+		}
+		profile.Banner = ""
+	}
+	if patch.Birthday != nil {
+		profile.Birthday = *patch.Birthday
+		if profile.Birthday != "" {
+			if msg := validateBirthday(profile.Birthday); msg != "" {
+				return profile, msg, nil
+			}
+		}
+	}
+	if patch.BirthdayVisibility != nil {
+		switch *patch.BirthdayVisibility {
+		case birthdayPublic, birthdayMonthDay, birthdayPrivate:
+			profile.BirthdayVisibility = *patch.BirthdayVisibility
+		default:
+			return profile, "Invalid birthdayVisibility", nil
+		}
+	}
+	if patch.PinnedPost != nil {
+		profile.PinnedPost = *patch.PinnedPost
+		if profile.PinnedPost != "" {
+			msg, err := s.checkPinnedPost(ctx, user, profile.PinnedPost)
+			if msg != "" || err != nil {
+				return profile, msg, err
+			}
+		}
+	}
+	return profile, "", nil
+}
+
+// Закрепить можно только свой пост, но не репост
+func (s *server) checkPinnedPost(ctx context.Context, user User, postID string) (string, error) {
+	id, err := primitive.ObjectIDFromHex(postID)
+	if err != nil {
+		return "Invalid pinnedPost", nil
+	}
+	post, err := s.posts.GetPost(ctx, id)
+	if errors.Is(err, errNotFound) {
+		return "Pinned post not found", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if post.Author != user.ID.Hex() || post.RepostOf != "" {
+		return "Only your own posts can be pinned", nil
+	}
+	return "", nil
+}
+
+// PATCH /users/{id}/profile — JSON или multipart-форма с файлом banner
+func (s *server) updateProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	if !authorize(w, r, id.Hex()) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	patch, err := readProfilePatch(r)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.users.GetUser(ctx, id)
+	if err != nil {
+		storeError(w, err, "User not found")
+		return
+	}
+
+	profile, msg, err := s.applyProfilePatch(ctx, user, patch)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	// Файл загружаем только после успешной валидации остальных полей
+	if r.MultipartForm != nil {
+		banner, err := s.uploadFormFile(ctx, r, "banner", false)
+		if err != nil {
+			uploadError(w, err)
+			return
+		}
+		if banner.URL != "" {
+			profile.Banner = banner.URL
+		}
+	}
+
+	updated, err := s.users.UpdateProfile(ctx, id, profile)
+	if err != nil {
+		storeError(w, err, "User not found")
+		return
+	}
+	if updated.Profile.BirthdayVisibility == "" {
+		updated.Profile.BirthdayVisibility = birthdayPrivate
+	}
+
+	json.NewEncoder(w).Encode(updated.Profile)
+}
+
+// GET /users/{id}/profile — публичный профиль
+func (s *server) getProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := s.users.GetUser(ctx, id)
+	if err != nil {
+		storeError(w, err, "User not found")
+		return
+	}
+
+	// Закреплённый пост мог быть удалён
+	if pinned := user.Profile.PinnedPost; pinned != "" {
+		if msg, err := s.checkPinnedPost(ctx, user, pinned); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else if msg != "" {
+			user.Profile.PinnedPost = ""
+		}
+	}
+
+	json.NewEncoder(w).Encode(publicProfile(user))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUpdateProfileValidation(t *testing.T) {
+	api := newTestAPI(t)
+	alice, aliceToken := api.register("alice")
+	_, bobToken := api.register("bob")
+	path := "/api/twitter/users/" + alice.ID.Hex() + "/profile"
+	bobPost := api.createPost(bobToken, "not yours")
+
+	tests := []struct {
+		name  string
+		patch map[string]string
+		want  int
+	}{
+		{"bio at limit", map[string]string{"bio": strings.Repeat("б", maxBioLength)}, http.StatusOK},
+		{"bio too long", map[string]string{"bio": strings.Repeat("б", maxBioLength+1)}, http.StatusBadRequest},
+		{"location too long", map[string]string{"location": strings.Repeat("x", maxLocationLength+1)}, http.StatusBadRequest},
+		{"website", map[string]string{"website": "https://example.com/me"}, http.StatusOK},
+		{"website without scheme", map[string]string{"website": "example.com"}, http.StatusBadRequest},
+		{"website javascript", map[string]string{"website": "javascript:alert(1)"}, http.StatusBadRequest},
+		{"website too long", map[string]string{"website": "https://example.com/" + strings.Repeat("a", maxWebsiteLength)}, http.StatusBadRequest},
+		{"banner as string", map[string]string{"banner": "https://example.com/b.png"}, http.StatusBadRequest},
+		{"birthday", map[string]string{"birthday": "1990-05-17"}, http.StatusOK},
+		{"birthday format", map[string]string{"birthday": "17.05.1990"}, http.StatusBadRequest},
+		{"birthday in future", map[string]string{"birthday": "2999-01-01"}, http.StatusBadRequest},
+		{"visibility", map[string]string{"birthdayVisibility": "friends"}, http.StatusBadRequest},
+		{"pinned foreign post", map[string]string{"pinnedPost": bobPost.Hex()}, http.StatusBadRequest},
+		{"pinned bad id", map[string]string{"pinnedPost": "nope"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := api.do("PATCH", path, aliceToken, tt.patch); rec.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+
+	// Отклонённые изменения не сохраняются
+	user, err := api.store.GetUser(t.Context(), alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Profile.Website != "https://example.com/me" || user.Profile.Birthday != "1990-05-17" || len([]rune(user.Profile.Bio)) != maxBioLength {
+		t.Errorf("stored profile = %+v", user.Profile)
+	}
+
+	api.expect(http.StatusForbidden, "PATCH", path, bobToken, map[string]string{"bio": "hacked"})
+	api.expect(http.StatusOK, "PATCH", path, aliceToken, map[string]string{"website": ""})
+
+	var profile PublicProfile
+	decodeJSON(t, api.expect(http.StatusOK, "GET", path, "", nil), &profile)
+	if profile.Website != "" || profile.Birthday != "" {
+		t.Errorf("public profile = %+v", profile)
+	}
+}
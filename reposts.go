@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type repostResponse struct {
+	Reposted    bool `json:"reposted"`
+	RepostCount int  `json:"repostCount"`
+}
+
+// id оригинала для репоста или цитаты; пусто у обычного поста
+func (p Post) OriginalID() string {
+	if p.RepostOf != "" {
+		return p.RepostOf
+	}
+	return p.QuoteOf
+}
+
+// Пост, на который ссылается {id}. Репост репоста ссылается на оригинал.
+func (s *server) originalPost(ctx context.Context, id primitive.ObjectID) (Post, error) {
+	post, err := s.posts.GetPost(ctx, id)
+	if err != nil || post.RepostOf == "" {
+		return post, err
+	}
+	originalID, err := primitive.ObjectIDFromHex(post.RepostOf)
+	if err != nil {
+		return Post{}, errNotFound
+	}
+	return s.posts.GetPost(ctx, originalID)
+}
+
+// Подставляет Original в репосты и цитаты одним запросом к хранилищу
+func (s *server) attachOriginals(ctx context.Context, posts []Post) error {
+	var ids []primitive.ObjectID
+	for _, p := range posts {
+		if id, err := primitive.ObjectIDFromHex(p.OriginalID()); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	originals, err := s.posts.ListPostsByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]Post, len(originals))
+	for _, o := range originals {
+		byID[o.ID.Hex()] = o
+	}
+	for i := range posts {
+		if o, ok := byID[posts[i].OriginalID()]; ok {
+			posts[i].Original = &o
+		}
+	}
+	return nil
+}
+
+// Репост {id} от имени вызывающего. Повторный запрос возвращает существующий репост.
+func (s *server) repostPost(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	original, err := s.originalPost(ctx, id)
+	if err != nil {
+		storeError(w, err, "Post not found")
+		return
+	}
+
+	user := userFromContext(r.Context())
+	repost := Post{
+		ID:         primitive.NewObjectID(),
+		Author:     user.ID.Hex(),
+		CreateDate: timestampNow(),
+		Comments:   []Comment{},
+		Reposts:    []PostRepost{},
+		Hashtags:   []string{},
+		Mentions:   []Mention{},
+		RepostOf:   original.ID.Hex(),
+	}
+
+	created, err := s.posts.CreateRepost(ctx, repost)
+	if err != nil {
+		storeError(w, err, "Post not found")
+		return
+	}
+	if !created {
+		if repost, err = s.posts.GetRepost(ctx, repost.Author, original.ID); err != nil {
+			storeError(w, err, "Post not found")
+			return
+		}
+	}
+
+	if err := s.hydratePost(ctx, &repost); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(repost)
+}
+
+// Снимает репост {id}; без репоста ничего не меняет
+func (s *server) unrepostPost(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	original, err := s.originalPost(ctx, id)
+	if err != nil {
+		storeError(w, err, "Post not found")
+		return
+	}
+
+	user := userFromContext(r.Context())
+	repost, err := s.posts.GetRepost(ctx, user.ID.Hex(), original.ID)
+	if err == nil {
+		err = s.posts.DeleteRepost(ctx, repost)
+	}
+	if err != nil && !errors.Is(err, errNotFound) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	original, err = s.posts.GetPost(ctx, original.ID)
+	if err != nil {
+		storeError(w, err, "Post not found")
+		return
+	}
+	json.NewEncoder(w).Encode(repostResponse{Reposted: false, RepostCount: original.RepostCount})
+}
+
+// Цитата: новый пост со своим текстом и ссылкой на оригинал
+func (s *server) quotePost(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Text = strings.TrimSpace(req.Text)
+	if req.Text == "" {
+		http.Error(w, "Text is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	original, err := s.originalPost(ctx, id)
+	if err != nil {
+		storeError(w, err, "Post not found")
+		return
+	}
+
+	user := userFromContext(r.Context())
+	quote := Post{
+		ID:         primitive.NewObjectID(),
+		Author:     user.ID.Hex(),
+		Text:       req.Text,
+		CreateDate: timestampNow(),
+		Comments:   []Comment{},
+		Reposts:    []PostRepost{},
+		Hashtags:   extractHashtags(req.Text),
+		QuoteOf:    original.ID.Hex(),
+	}
+
+	if quote.Mentions, err = s.resolveMentions(ctx, quote.Text); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := s.posts.CreateRepost(ctx, quote); err != nil {
+		storeError(w, err, "Post not found")
+		return
+	}
+	s.notifyMentions(ctx, quote.Author, quote.ID, "", quote.Mentions)
+
+	quote.Original = &original
+	quote.Original.QuoteCount++
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(quote)
+}
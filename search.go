@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	searchSortRelevance = "relevance"
+	searchSortRecent    = "recent"
+
+	// Сортировка по релевантности ранжирует не больше стольких результатов
+	maxSearchResults = 1000
+)
+
+// Разобранный поисковый запрос
+type SearchQuery struct {
+	// Отдельные слова: достаточно совпадения любого
+	Terms []string
+	// Фразы в кавычках: должны встречаться все
+	Phrases []string
+	// Только посты этого автора
+	Author string
+	// Посты, созданные в [Since, Until); нулевые — без ограничения
+	Since, Until time.Time
+	Sort         string
+}
+
+var searchPhrase = regexp.MustCompile(`"([^"]*)"`)
+
+// Разбирает q: фразы берутся в кавычки, остальное — отдельные слова.
+// @ перед словом отбрасывается, чтобы @handle находил пользователя.
+func parseSearchQuery(q string) SearchQuery {
+	var query SearchQuery
+	for _, m := range searchPhrase.FindAllStringSubmatch(q, -1) {
+		if phrase := strings.Join(strings.Fields(m[1]), " "); phrase != "" {
+			query.Phrases = append(query.Phrases, phrase)
+		}
+	}
+	rest := searchPhrase.ReplaceAllString(q, " ")
+	for _, term := range strings.Fields(strings.ReplaceAll(rest, `"`, " ")) {
+		if term = strings.TrimLeft(term, "@"); term != "" {
+			query.Terms = append(query.Terms, term)
+		}
+	}
+	return query
+}
+
+func (q SearchQuery) empty() bool {
+	return len(q.Terms) == 0 && len(q.Phrases) == 0
+}
+
+// Дата фильтра: RFC 3339 или YYYY-MM-DD
+func parseSearchDate(s string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// GET /search?q=&type=posts|users&sort=relevance|recent&author=&since=&until=
+//
+// Фразы пишутся в кавычках: q="hello world" go. author, since и until
+// фильтруют посты. Пагинация курсорная, как в остальных списках.
+func (s *server) search(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.URL.Query()
+	query := parseSearchQuery(params.Get("q"))
+	if query.empty() {
+		http.Error(w, "Query is required", http.StatusBadRequest)
+		return
+	}
+
+	query.Sort = params.Get("sort")
+	if query.Sort == "" {
+		query.Sort = searchSortRelevance
+	}
+	if query.Sort != searchSortRelevance && query.Sort != searchSortRecent {
+		http.Error(w, "Invalid sort", http.StatusBadRequest)
+		return
+	}
+
+	searchType := params.Get("type")
+	if searchType == "" {
+		searchType = "posts"
+	}
+	if searchType != "posts" && searchType != "users" {
+		http.Error(w, "Invalid type", http.StatusBadRequest)
+		return
+	}
+
+	if author := params.Get("author"); author != "" {
+		if _, err := primitive.ObjectIDFromHex(author); err != nil {
+			http.Error(w, "Invalid author", http.StatusBadRequest)
+			return
+		}
+		query.Author = author
+	}
+	for key, dst := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		if v := params.Get(key); v != "" {
+			t, ok := parseSearchDate(v)
+			if !ok {
+				http.Error(w, "Invalid "+key, http.StatusBadRequest)
+				return
+			}
+			*dst = t
+		}
+	}
+	if searchType == "users" && (query.Author != "" || !query.Since.IsZero() || !query.Until.IsZero()) {
+		http.Error(w, "author, since and until apply to posts only", http.StatusBadRequest)
+		return
+	}
+
+	page, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if searchType == "users" {
+		users, err := s.searcher.SearchUsers(ctx, query, page)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := s.hydrateUsers(ctx, users); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writePage(w, viewUsers(userFromContext(r.Context()), users), page, viewID)
+		return
+	}
+
+	posts, err := s.searcher.SearchPosts(ctx, query, page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydratePosts(ctx, posts); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writePage(w, posts, page, func(p Post) primitive.ObjectID { return p.ID })
+}
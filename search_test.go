@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		q       string
+		terms   []string
+		phrases []string
+	}{
+		{"go rust", []string{"go", "rust"}, nil},
+		{`"hello   world" go`, []string{"go"}, []string{"hello world"}},
+		{"@alice @@bob @", []string{"alice", "bob"}, nil},
+		{`unclosed "quote`, []string{"unclosed", "quote"}, nil},
+		{`""`, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.q, func(t *testing.T) {
+			query := parseSearchQuery(tt.q)
+			if !slices.Equal(query.Terms, tt.terms) || !slices.Equal(query.Phrases, tt.phrases) {
+				t.Errorf("terms %q phrases %q, want %q %q", query.Terms, query.Phrases, tt.terms, tt.phrases)
+			}
+		})
+	}
+}
+
+// Имена результатов поиска пользователей в порядке выдачи
+func searchUserNames(t *testing.T, store *memoryStore, q string) []string {
+	t.Helper()
+	query := parseSearchQuery(q)
+	query.Sort = searchSortRelevance
+	users, err := store.SearchUsers(context.Background(), query, Page{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, u := range users {
+		names = append(names, u.Name)
+	}
+	return names
+}
+
+func TestMemorySearchUsers(t *testing.T) {
+	store := newMemoryStore()
+	for _, u := range []User{
+		{Name: "Alice Smith", Handle: "wonderland"},
+		{Name: "Bob", Handle: "alice_fan"},
+		{Name: "Carol", Handle: "carol"},
+		{Name: "No Handle"},
+	} {
+		u.ID = primitive.NewObjectID()
+		u.GoogleID, u.Email = u.Name, u.Name+"@example.com"
+		if err := store.CreateUser(context.Background(), u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{"alice", []string{"Alice Smith"}},
+		{"wonderland", []string{"Alice Smith"}},
+		{"@wonderland", []string{"Alice Smith"}},
+		{"ALICE_FAN", []string{"Bob"}},
+		{"@carol", []string{"Carol"}},
+		{`"alice smith"`, []string{"Alice Smith"}},
+		{"carol bob", []string{"Carol", "Bob"}},
+		{"handle", []string{"No Handle"}},
+		{"nobody", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.q, func(t *testing.T) {
+			got := searchUserNames(t, store, tt.q)
+			slices.Sort(got)
+			want := slices.Clone(tt.want)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("found %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestMemorySearchPosts(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	author := primitive.NewObjectID().Hex()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	texts := []string{"go is fun", "go go go", "rust and go", "hello world", "say hello world again"}
+	ids := make([]primitive.ObjectID, len(texts))
+	for i, text := range texts {
+		ids[i] = primitive.NewObjectIDFromTimestamp(base.Add(time.Duration(i) * time.Hour))
+		post := Post{ID: ids[i], Text: text, Author: "someone", CreateDate: newTimestamp(base.Add(time.Duration(i) * time.Hour))}
+		if i == 2 {
+			post.Author = author
+		}
+		if err := store.CreatePost(ctx, post); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search := func(query SearchQuery, page Page) []primitive.ObjectID {
+		t.Helper()
+		if query.Sort == "" {
+			query.Sort = searchSortRelevance
+		}
+		posts, err := store.SearchPosts(ctx, query, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []primitive.ObjectID
+		for _, p := range posts {
+			got = append(got, p.ID)
+		}
+		return got
+	}
+	all := Page{Limit: 10}
+
+	tests := []struct {
+		name  string
+		query SearchQuery
+		want  []primitive.ObjectID
+	}{
+		// Больше совпадений — выше; при равенстве новые раньше
+		{"relevance", SearchQuery{Terms: []string{"go"}}, []primitive.ObjectID{ids[1], ids[2], ids[0]}},
+		{"recent", SearchQuery{Terms: []string{"go"}, Sort: searchSortRecent}, []primitive.ObjectID{ids[2], ids[1], ids[0]}},
+		{"any term", SearchQuery{Terms: []string{"rust", "fun"}}, []primitive.ObjectID{ids[2], ids[0]}},
+		{"phrase", SearchQuery{Phrases: []string{"hello world"}}, []primitive.ObjectID{ids[4], ids[3]}},
+		{"phrase and term", SearchQuery{Phrases: []string{"hello world"}, Terms: []string{"again"}}, []primitive.ObjectID{ids[4], ids[3]}},
+		{"author", SearchQuery{Terms: []string{"go"}, Author: author}, []primitive.ObjectID{ids[2]}},
+		{"since", SearchQuery{Terms: []string{"go"}, Since: base.Add(time.Hour)}, []primitive.ObjectID{ids[1], ids[2]}},
+		{"until", SearchQuery{Terms: []string{"go"}, Until: base.Add(time.Hour)}, []primitive.ObjectID{ids[0]}},
+		{"no match", SearchQuery{Terms: []string{"python"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := search(tt.query, all); !slices.Equal(got, tt.want) {
+				t.Errorf("found %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// По релевантности следующая страница начинается после последнего элемента
+	query := SearchQuery{Terms: []string{"go"}}
+	first := search(query, Page{Limit: 1})
+	if len(first) != 2 || first[0] != ids[1] {
+		t.Fatalf("first page = %v", first)
+	}
+	if second := search(query, Page{Limit: 1, After: first[0]}); len(second) != 2 || second[0] != ids[2] {
+		t.Errorf("second page = %v", second)
+	}
+}
+
+func TestSearchUsersByHandle(t *testing.T) {
+	api := newTestAPI(t)
+	alice, token := api.register("alice")
+	api.expect(http.StatusOK, "PUT", "/api/twitter/users/"+alice.ID.Hex()+"/handle", token, map[string]string{"handle": "wonder_land"})
+
+	var page pageResponse[PublicUser]
+	decodeJSON(t, api.expect(http.StatusOK, "GET", "/api/twitter/search?type=users&q=%40wonder_land", "", nil), &page)
+	if len(page.Data) != 1 || page.Data[0].ID != alice.ID {
+		t.Errorf("search by handle = %+v", page.Data)
+	}
+}
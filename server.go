@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// server держит зависимости обработчиков. Хранилища подставляются
+// через интерфейсы, поэтому API можно поднять поверх memoryStore.
+type server struct {
+	users    UserStore
+	posts    PostStore
+	chats    ChatStore
+	messages MessageStore
+	notices  NoticeStore
+	sessions SessionStore
+	searcher SearchIndex
+
+	trends *trendCache
+
+	media         MediaStore
+	verifier      *googleVerifier
+	sessionSecret []byte
+}
+
+func newServer(store Store, media MediaStore, verifier *googleVerifier, sessionSecret []byte) *server {
+	return &server{
+		users:         store,
+		posts:         store,
+		chats:         store,
+		messages:      store,
+		notices:       store,
+		sessions:      store,
+		searcher:      store,
+		trends:        newTrendCache(),
+		media:         media,
+		verifier:      verifier,
+		sessionSecret: sessionSecret,
+	}
+}
+
+func (s *server) routes() http.Handler {
+	// Создание маршрутизатора
+	router := mux.NewRouter()
+
+	// Базовый маршрут
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Hello from API")
+	}).Methods("GET")
+
+	// Локальные файлы раздаём сами
+	if h, ok := s.media.(http.Handler); ok {
+		router.PathPrefix(mediaRoute).Handler(h).Methods("GET", "HEAD")
+	}
+
+	// Маршруты API
+	api := router.PathPrefix("/api/twitter").Subrouter()
+	api.Use(s.authenticate)
+
+	// Auth Routes
+	api.HandleFunc("/auth/login", requireIdentity(s.login)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/auth/google", requireIdentity(s.googleAuth)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/auth/refresh", s.refreshSession).Methods("POST", "OPTIONS")
+	api.HandleFunc("/auth/logout", requireUser(s.logout)).Methods("POST", "OPTIONS")
+
+	// User Routes
+	api.HandleFunc("/users", s.getUsers).Methods("GET", "OPTIONS")
+	api.HandleFunc("/users", requireIdentity(s.createUser)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/users/check-existence", s.checkUserExistence).Methods("POST", "OPTIONS")
+	api.HandleFunc("/users/{id}", requireUser(s.deleteUser)).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/users/{id}", requireUser(s.updateUser)).Methods("PUT", "PATCH", "OPTIONS")
+	api.HandleFunc("/users/{googleId}", s.getUserByGoogleID).Methods("GET", "OPTIONS")
+	api.HandleFunc("/users/me/bookmarks", requireUser(s.getMyBookmarks)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/users/by-handle/{handle}", s.getUserByHandle).Methods("GET", "OPTIONS")
+	api.HandleFunc("/users/{id}/handle", requireUser(s.changeHandle)).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/users/{id}/handles", s.getHandleHistory).Methods("GET", "OPTIONS")
+	api.HandleFunc("/users/{id}/profile", s.getProfile).Methods("GET", "OPTIONS")
+	api.HandleFunc("/users/{id}/profile", requireUser(s.updateProfile)).Methods("PATCH", "OPTIONS")
+	api.HandleFunc("/users/{id}/follow", requireUser(s.followUser)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/users/{id}/follow", requireUser(s.unfollowUser)).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/users/{id}/block", requireUser(s.blockUser)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/users/{id}/block", requireUser(s.unblockUser)).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/users/{id}/timeline", requireUser(s.getTimeline)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/users/{id}/mentions", s.getUserMentions).Methods("GET", "OPTIONS")
+
+	// Post Routes
+	api.HandleFunc("/posts", s.getPosts).Methods("GET", "OPTIONS")
+	api.HandleFunc("/posts", requireUser(s.createPost)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/posts/{id}", s.getPostByID).Methods("GET", "OPTIONS")
+	api.HandleFunc("/posts/{id}", requireUser(s.deletePost)).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/posts/{id}", requireUser(s.updatePost)).Methods("PUT", "PATCH", "OPTIONS")
+	api.HandleFunc("/posts/{id}/like", requireUser(s.likePost)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/posts/{id}/like", requireUser(s.unlikePost)).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/posts/{id}/likes", s.getPostLikes).Methods("GET", "OPTIONS")
+	api.HandleFunc("/posts/{id}/bookmark", requireUser(s.bookmarkPost)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/posts/{id}/bookmark", requireUser(s.unbookmarkPost)).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/posts/{id}/repost", requireUser(s.repostPost)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/posts/{id}/repost", requireUser(s.unrepostPost)).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/posts/{id}/quote", requireUser(s.quotePost)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/posts/{id}/comments", s.getComments).Methods("GET", "OPTIONS")
+	api.HandleFunc("/posts/{id}/comments", requireUser(s.createComment)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/posts/{id}/comments/{commentId}", requireUser(s.updateComment)).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/posts/{id}/comments/{commentId}", requireUser(s.deleteComment)).Methods("DELETE", "OPTIONS")
+
+	// Search Routes
+	api.HandleFunc("/search", s.search).Methods("GET", "OPTIONS")
+	api.HandleFunc("/hashtags/{tag}/posts", s.getHashtagPosts).Methods("GET", "OPTIONS")
+	api.HandleFunc("/trends", s.getTrends).Methods("GET", "OPTIONS")
+
+	// Chat Routes
+	api.HandleFunc("/chat", requireUser(s.getChats)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/chat", requireUser(s.createChat)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/chat/{idd}", requireUser(s.getChatsByIDD)).Methods("GET", "OPTIONS")
+
+	// Message Routes
+	api.HandleFunc("/messages", requireUser(s.getMessages)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/messages", requireUser(s.sendMessage)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/messages/{id}", requireUser(s.getMessageByID)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/messages/{id}", requireUser(s.deleteMessage)).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/messages/{id}", requireUser(s.updateMessage)).Methods("PUT", "PATCH", "OPTIONS")
+
+	// Notice Routes
+	api.HandleFunc("/notices", s.getNotices).Methods("GET", "OPTIONS")
+	api.HandleFunc("/notices", requireUser(s.createNotice)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/notices/{id}", requireUser(s.deleteNotice)).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/notices/{id}", requireUser(s.updateNotice)).Methods("PUT", "PATCH", "OPTIONS")
+
+	// Добавляем middleware для CORS
+	return enableCORS(router)
+}
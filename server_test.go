@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// Сценарии API целиком поверх memoryStore, без базы
+
+func TestPostPagination(t *testing.T) {
+	api := newTestAPI(t)
+	_, token := api.register("alice")
+
+	created := map[string]bool{}
+	for i := 0; i < 5; i++ {
+		created[api.createPost(token, fmt.Sprintf("post %d", i)).Hex()] = true
+	}
+
+	seen := map[string]bool{}
+	path := "/api/twitter/posts?limit=2"
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("too many pages")
+		}
+		var page pageResponse[Post]
+		decodeJSON(t, api.expect(http.StatusOK, "GET", path, "", nil), &page)
+		for _, p := range page.Data {
+			if seen[p.ID.Hex()] {
+				t.Fatalf("post %s returned twice", p.ID.Hex())
+			}
+			seen[p.ID.Hex()] = true
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		path = "/api/twitter/posts?limit=2&cursor=" + *page.NextCursor
+	}
+	if len(seen) != len(created) {
+		t.Errorf("paged through %d posts, want %d", len(seen), len(created))
+	}
+
+	api.expect(http.StatusBadRequest, "GET", "/api/twitter/posts?cursor=zz", "", nil)
+}
+
+func TestFollowTimeline(t *testing.T) {
+	api := newTestAPI(t)
+	alice, aliceToken := api.register("alice")
+	bob, bobToken := api.register("bob")
+	_, carolToken := api.register("carol")
+
+	bobPost := api.createPost(bobToken, "from bob")
+	api.createPost(carolToken, "from carol")
+
+	follow := "/api/twitter/users/" + bob.ID.Hex() + "/follow"
+	api.expect(http.StatusCreated, "POST", follow, aliceToken, nil)
+	// Повторная подписка ничего не меняет
+	api.expect(http.StatusOK, "POST", follow, aliceToken, nil)
+	api.expect(http.StatusBadRequest, "POST", "/api/twitter/users/"+alice.ID.Hex()+"/follow", aliceToken, nil)
+
+	timeline := "/api/twitter/users/" + alice.ID.Hex() + "/timeline"
+	var page pageResponse[TimelineItem]
+	decodeJSON(t, api.expect(http.StatusOK, "GET", timeline, aliceToken, nil), &page)
+	if len(page.Data) != 1 || page.Data[0].Post.ID != bobPost {
+		t.Errorf("timeline = %+v, want only bob's post", page.Data)
+	}
+	api.expect(http.StatusForbidden, "GET", timeline, carolToken, nil)
+
+	// Репост подписки показывается как оригинал с автором репоста
+	carolPost := api.createPost(carolToken, "reposted")
+	api.expect(http.StatusCreated, "POST", "/api/twitter/posts/"+carolPost.Hex()+"/repost", bobToken, nil)
+	decodeJSON(t, api.expect(http.StatusOK, "GET", timeline, aliceToken, nil), &page)
+	if len(page.Data) != 2 || page.Data[0].Post.ID != carolPost || page.Data[0].RepostedBy != bob.ID.Hex() || page.Data[1].RepostedBy != "" {
+		t.Errorf("timeline with repost = %+v", page.Data)
+	}
+
+	var bobView SelfUser
+	decodeJSON(t, api.expect(http.StatusOK, "GET", "/api/twitter/users/bob", bobToken, nil), &bobView)
+	if len(bobView.Subscribers) != 1 || bobView.Subscribers[0].User != alice.ID.Hex() {
+		t.Errorf("subscribers = %+v", bobView.Subscribers)
+	}
+
+	api.expect(http.StatusOK, "DELETE", follow, aliceToken, nil)
+	decodeJSON(t, api.expect(http.StatusOK, "GET", timeline, aliceToken, nil), &page)
+	if len(page.Data) != 0 {
+		t.Errorf("timeline after unfollow = %+v", page.Data)
+	}
+}
+
+func TestLikeCounter(t *testing.T) {
+	api := newTestAPI(t)
+	_, aliceToken := api.register("alice")
+	_, bobToken := api.register("bob")
+	like := "/api/twitter/posts/" + api.createPost(aliceToken, "hi").Hex() + "/like"
+
+	var result struct {
+		Liked bool `json:"liked"`
+		Likes int  `json:"likes"`
+	}
+	api.expect(http.StatusCreated, "POST", like, bobToken, nil)
+	decodeJSON(t, api.expect(http.StatusOK, "POST", like, bobToken, nil), &result)
+	if !result.Liked || result.Likes != 1 {
+		t.Errorf("after double like: %+v", result)
+	}
+	api.expect(http.StatusOK, "DELETE", like, bobToken, nil)
+	decodeJSON(t, api.expect(http.StatusOK, "DELETE", like, bobToken, nil), &result)
+	if result.Liked || result.Likes != 0 {
+		t.Errorf("after double unlike: %+v", result)
+	}
+}
+
+func TestBookmarksArePrivate(t *testing.T) {
+	api := newTestAPI(t)
+	_, aliceToken := api.register("alice")
+	_, bobToken := api.register("bob")
+	post := api.createPost(aliceToken, "hi")
+
+	api.expect(http.StatusCreated, "POST", "/api/twitter/posts/"+post.Hex()+"/bookmark", bobToken, nil)
+
+	var page pageResponse[BookmarkItem]
+	decodeJSON(t, api.expect(http.StatusOK, "GET", "/api/twitter/users/me/bookmarks", bobToken, nil), &page)
+	if len(page.Data) != 1 || page.Data[0].Post == nil || page.Data[0].Post.ID != post {
+		t.Errorf("bob's bookmarks = %+v", page.Data)
+	}
+	decodeJSON(t, api.expect(http.StatusOK, "GET", "/api/twitter/users/me/bookmarks", aliceToken, nil), &page)
+	if len(page.Data) != 0 {
+		t.Errorf("alice's bookmarks = %+v", page.Data)
+	}
+	api.expect(http.StatusUnauthorized, "GET", "/api/twitter/users/me/bookmarks", "", nil)
+}
+
+func TestCommentReplies(t *testing.T) {
+	api := newTestAPI(t)
+	_, aliceToken := api.register("alice")
+	_, bobToken := api.register("bob")
+	comments := "/api/twitter/posts/" + api.createPost(aliceToken, "hi").Hex() + "/comments"
+
+	var root Comment
+	decodeJSON(t, api.expect(http.StatusCreated, "POST", comments, bobToken, map[string]string{"text": "first"}), &root)
+	var reply Comment
+	decodeJSON(t, api.expect(http.StatusCreated, "POST", comments, aliceToken,
+		map[string]string{"text": "reply", "parentId": root.ID.Hex()}), &reply)
+	if reply.Depth != 1 || reply.ParentID != root.ID.Hex() {
+		t.Errorf("reply = %+v", reply)
+	}
+
+	api.expect(http.StatusBadRequest, "POST", comments, bobToken, map[string]string{"text": ""})
+	api.expect(http.StatusForbidden, "PUT", comments+"/"+root.ID.Hex(), aliceToken, map[string]string{"text": "x"})
+}
+
+func TestRepostIsIdempotent(t *testing.T) {
+	api := newTestAPI(t)
+	_, aliceToken := api.register("alice")
+	_, bobToken := api.register("bob")
+	post := api.createPost(aliceToken, "hi")
+	repost := "/api/twitter/posts/" + post.Hex() + "/repost"
+
+	api.expect(http.StatusCreated, "POST", repost, bobToken, nil)
+	api.expect(http.StatusOK, "POST", repost, bobToken, nil)
+
+	var original Post
+	decodeJSON(t, api.expect(http.StatusOK, "GET", "/api/twitter/posts/"+post.Hex(), "", nil), &original)
+	if original.RepostCount != 1 {
+		t.Errorf("repostCount = %d, want 1", original.RepostCount)
+	}
+}
+
+func TestUserListIncludesEdges(t *testing.T) {
+	api := newTestAPI(t)
+	alice, aliceToken := api.register("alice")
+	bob, _ := api.register("bob")
+	api.expect(http.StatusCreated, "POST", "/api/twitter/users/"+bob.ID.Hex()+"/follow", aliceToken, nil)
+
+	var single PublicUser
+	decodeJSON(t, api.expect(http.StatusOK, "GET", "/api/twitter/users/bob", "", nil), &single)
+
+	var list pageResponse[PublicUser]
+	decodeJSON(t, api.expect(http.StatusOK, "GET", "/api/twitter/users", "", nil), &list)
+	var listed *PublicUser
+	for i := range list.Data {
+		if list.Data[i].ID == bob.ID {
+			listed = &list.Data[i]
+		}
+	}
+	if listed == nil {
+		t.Fatal("bob is missing from the user list")
+	}
+	if len(listed.Subscribers) != 1 || listed.Subscribers[0].User != alice.ID.Hex() {
+		t.Errorf("listed subscribers = %+v", listed.Subscribers)
+	}
+	if len(single.Subscribers) != len(listed.Subscribers) {
+		t.Errorf("single view has %d subscribers, list has %d", len(single.Subscribers), len(listed.Subscribers))
+	}
+}
+
+func TestConversationsArePrivate(t *testing.T) {
+	api := newTestAPI(t)
+	_, aliceToken := api.register("alice")
+	bob, bobToken := api.register("bob")
+	_, carolToken := api.register("carol")
+	_, adminToken := api.registerAdmin("admin")
+
+	api.expect(http.StatusCreated, "POST", "/api/twitter/messages", aliceToken, map[string]string{"id": "ab", "receiver": bob.ID.Hex()})
+	api.expect(http.StatusCreated, "POST", "/api/twitter/chat", aliceToken, map[string]string{"idd": "ab", "text": "hi"})
+	api.expect(http.StatusCreated, "POST", "/api/twitter/chat", carolToken, map[string]string{"idd": "room", "text": "solo"})
+
+	for _, path := range []string{"/api/twitter/chat", "/api/twitter/chat/ab", "/api/twitter/messages", "/api/twitter/messages/ab"} {
+		api.expect(http.StatusUnauthorized, "GET", path, "", nil)
+	}
+
+	// Собеседник видит переписку, посторонний — нет
+	api.expect(http.StatusOK, "GET", "/api/twitter/chat/ab", bobToken, nil)
+	api.expect(http.StatusOK, "GET", "/api/twitter/messages/ab", bobToken, nil)
+	api.expect(http.StatusForbidden, "GET", "/api/twitter/chat/ab", carolToken, nil)
+	api.expect(http.StatusForbidden, "GET", "/api/twitter/messages/ab", carolToken, nil)
+	api.expect(http.StatusOK, "GET", "/api/twitter/messages/ab", adminToken, nil)
+
+	// Чужую переписку нельзя пополнить
+	api.expect(http.StatusForbidden, "POST", "/api/twitter/chat", carolToken, map[string]string{"idd": "ab", "text": "me too"})
+	api.expect(http.StatusForbidden, "POST", "/api/twitter/messages", carolToken, map[string]string{"id": "ab", "receiver": bob.ID.Hex()})
+
+	var chats pageResponse[Chat]
+	decodeJSON(t, api.expect(http.StatusOK, "GET", "/api/twitter/chat", carolToken, nil), &chats)
+	if len(chats.Data) != 1 || chats.Data[0].IDD != "room" {
+		t.Errorf("carol's chats = %+v", chats.Data)
+	}
+	var messages pageResponse[Message]
+	decodeJSON(t, api.expect(http.StatusOK, "GET", "/api/twitter/messages", carolToken, nil), &messages)
+	if len(messages.Data) != 0 {
+		t.Errorf("carol's messages = %+v", messages.Data)
+	}
+	decodeJSON(t, api.expect(http.StatusOK, "GET", "/api/twitter/messages", bobToken, nil), &messages)
+	if len(messages.Data) != 1 {
+		t.Errorf("bob's messages = %+v", messages.Data)
+	}
+	decodeJSON(t, api.expect(http.StatusOK, "GET", "/api/twitter/chat", adminToken, nil), &chats)
+	if len(chats.Data) != 2 {
+		t.Errorf("admin sees %d chats, want 2", len(chats.Data))
+	}
+}
+
+func TestDeleteUserCascades(t *testing.T) {
+	api := newTestAPI(t)
+	alice, aliceToken := api.register("alice")
+	_, bobToken := api.register("bob")
+	ctx := context.Background()
+
+	bobPost := api.createPost(bobToken, "bob's post")
+	alicePost := api.createPost(aliceToken, "alice's post")
+	api.expect(http.StatusCreated, "POST", "/api/twitter/posts/"+bobPost.Hex()+"/like", aliceToken, nil)
+	api.expect(http.StatusCreated, "POST", "/api/twitter/posts/"+bobPost.Hex()+"/repost", aliceToken, nil)
+	api.expect(http.StatusCreated, "POST", "/api/twitter/posts/"+alicePost.Hex()+"/repost", bobToken, nil)
+	api.expect(http.StatusCreated, "POST", "/api/twitter/posts/"+alicePost.Hex()+"/like", bobToken, nil)
+
+	api.expect(http.StatusOK, "DELETE", "/api/twitter/users/"+alice.ID.Hex(), aliceToken, nil)
+
+	post, err := api.store.GetPost(ctx, bobPost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.Likes != 0 || post.RepostCount != 0 {
+		t.Errorf("bob's post after delete: likes %d, reposts %d", post.Likes, post.RepostCount)
+	}
+	for _, p := range api.store.posts {
+		if p.ID != bobPost {
+			t.Errorf("post %+v left behind", p)
+		}
+	}
+	if len(api.store.likes) != 0 {
+		t.Errorf("likes left behind: %+v", api.store.likes)
+	}
+	api.expect(http.StatusUnauthorized, "POST", "/api/twitter/posts", aliceToken, map[string]string{"text": "still here"})
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	sessionIssuer   = "social_network_backend_go"
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// Сессия, выданная после входа через Google. Refresh-токен вида
+// «id сессии.случайная часть» меняется при каждом обновлении; хранятся
+// только хеши. Повторное предъявление уже обменянного токена — признак
+// утечки, и сессия отзывается.
+type Session struct {
+	ID          primitive.ObjectID `json:"_id" bson:"_id"`
+	User        string             `json:"user" bson:"user"`
+	RefreshHash string             `json:"-" bson:"refreshHash"`
+	// Хеши всех обменянных refresh-токенов сессии
+	PreviousHashes []string  `json:"-" bson:"previousHashes"`
+	CreateDate     time.Time `json:"createDate" bson:"createDate"`
+	ExpiresAt      time.Time `json:"expiresAt" bson:"expiresAt"`
+	Revoked        bool      `json:"revoked" bson:"revoked"`
+}
+
+type sessionClaims struct {
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+type tokenPair struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	TokenType    string `json:"tokenType"`
+	ExpiresIn    int    `json:"expiresIn"`
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newRefreshToken(sessionID primitive.ObjectID) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return sessionID.Hex() + "." + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Сессия, к которой относится refresh-токен
+func refreshTokenSession(token string) (primitive.ObjectID, bool) {
+	sid, secret, ok := strings.Cut(token, ".")
+	if !ok || secret == "" {
+		return primitive.NilObjectID, false
+	}
+	id, err := primitive.ObjectIDFromHex(sid)
+	return id, err == nil
+}
+
+func (s *server) signAccessToken(userID string, sessionID primitive.ObjectID) (string, error) {
+	now := time.Now()
+	claims := sessionClaims{
+		SessionID: sessionID.Hex(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    sessionIssuer,
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.sessionSecret)
+}
+
+func (s *server) parseAccessToken(raw string) (*sessionClaims, error) {
+	var claims sessionClaims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(t *jwt.Token) (interface{}, error) {
+		return s.sessionSecret, nil
+	},
+		jwt.WithValidMethods([]string{"HS256"}),
+		jwt.WithIssuer(sessionIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+func (s *server) issueTokens(session Session, refreshToken string) (tokenPair, error) {
+	access, err := s.signAccessToken(session.User, session.ID)
+	if err != nil {
+		return tokenPair{}, err
+	}
+	return tokenPair{
+		AccessToken:  access,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+	}, nil
+}
+
+// --- Auth Handlers ---
+
+// Обмен Google ID-токена на собственную пару токенов
+func (s *server) login(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := userFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tokens, err := s.startSession(ctx, *user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// Ответ /auth/google: пара токенов и пользователь
+type googleAuthResponse struct {
+	tokenPair
+	User    userView `json:"user"`
+	Created bool     `json:"created"`
+}
+
+// Вход или регистрация по Google ID-токену одним запросом. Пользователь
+// ищется по googleId и создаётся, если его нет; тело ({name, avatar})
+// необязательно и используется только при создании.
+// 201 — пользователь создан, 200 — вошёл существующий.
+func (s *server) googleAuth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var user User
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&user); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if !newUserFromIdentity(w, identityFromContext(r.Context()), &user) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, created, err := s.users.UpsertGoogleUser(ctx, user)
+	if errors.Is(err, errConflict) {
+		// email занят аккаунтом с другим googleId
+		http.Error(w, "User already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tokens, err := s.startSession(ctx, user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydrateUser(ctx, &user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(googleAuthResponse{tokenPair: tokens, User: viewUser(&user, user), Created: created})
+}
+
+// Создаёт сессию пользователя и выдаёт для неё пару токенов
+func (s *server) startSession(ctx context.Context, user User) (tokenPair, error) {
+	id := primitive.NewObjectID()
+	refreshToken, err := newRefreshToken(id)
+	if err != nil {
+		return tokenPair{}, err
+	}
+
+	now := time.Now()
+	session := Session{
+		ID:          id,
+		User:        user.ID.Hex(),
+		RefreshHash: hashRefreshToken(refreshToken),
+		CreateDate:  now,
+		ExpiresAt:   now.Add(refreshTokenTTL),
+	}
+	if err := s.sessions.CreateSession(ctx, session); err != nil {
+		return tokenPair{}, err
+	}
+	return s.issueTokens(session, refreshToken)
+}
+
+func (s *server) refreshSession(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var body struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.RefreshToken == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	sessionID, ok := refreshTokenSession(body.RefreshToken)
+	if !ok {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	newToken, err := newRefreshToken(sessionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	oldHash := hashRefreshToken(body.RefreshToken)
+	session, err := s.sessions.RotateSession(ctx, sessionID, oldHash, hashRefreshToken(newToken))
+	if errors.Is(err, errNotFound) {
+		// Сессию отзываем, только если токен действительно был выдан и уже
+		// обменян. Подделанный токен с чужим id сессии ничего не меняет.
+		if _, err := s.sessions.RevokeReusedSession(ctx, sessionID, oldHash); err != nil {
+			log.Printf("Error revoking session: %v", err)
+		}
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tokens, err := s.issueTokens(session, newToken)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tokens)
+}
+
+func (s *server) logout(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	session := sessionFromContext(r.Context())
+	if session == nil {
+		http.Error(w, "Session token required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var err error
+	if r.URL.Query().Get("all") == "true" {
+		err = s.sessions.RevokeUserSessions(ctx, session.User)
+	} else {
+		err = s.sessions.RevokeSession(ctx, session.ID)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out successfully"})
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Ошибки хранилищ, не зависящие от конкретной базы
+var (
+	errNotFound = errors.New("not found")
+	// Нарушен уникальный индекс (email, googleId и т.п.)
+	errConflict = errors.New("already exists")
+)
+
+// List-методы возвращают элементы от новых к старым, до page.Limit+1 штук
+
+type UserStore interface {
+	// errConflict, если email или googleId уже заняты
+	CreateUser(ctx context.Context, user User) error
+	// Находит пользователя по user.GoogleID или создаёт его из user.
+	// true, если пользователь создан.
+	UpsertGoogleUser(ctx context.Context, user User) (User, bool, error)
+	ListUsers(ctx context.Context, page Page) ([]User, error)
+	GetUser(ctx context.Context, id primitive.ObjectID) (User, error)
+	GetUserByGoogleID(ctx context.Context, googleID string) (User, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	GetUserByHandle(ctx context.Context, handle string) (User, error)
+	// Меняет handle пользователя change.User с change.Old на change.New и
+	// записывает смену в историю. errConflict, если New занят;
+	// errHandleChanged, если текущий handle уже не change.Old.
+	ChangeHandle(ctx context.Context, change HandleChange) error
+	// Смены handle пользователя, от новых к старым
+	ListHandleChanges(ctx context.Context, userID string) ([]HandleChange, error)
+	// Последняя смена, в которой handle был старым (Old)
+	GetLastHandleChange(ctx context.Context, handle string) (HandleChange, error)
+	// Пользователи с перечисленными handle; ненайденные пропускаются
+	ListUsersByHandles(ctx context.Context, handles []string) ([]User, error)
+	// Существующие пользователи из списка; отсутствующие id пропускаются
+	ListUsersByIDs(ctx context.Context, ids []primitive.ObjectID) ([]User, error)
+	// Записывает из updates только поля fields (имена в базе); остальные
+	// поля могли измениться параллельно и не перезаписываются
+	UpdateUser(ctx context.Context, id primitive.ObjectID, updates User, fields []string) (User, error)
+	// Заменяет только профиль пользователя
+	UpdateProfile(ctx context.Context, id primitive.ObjectID, profile UserProfile) (User, error)
+	// User.Posts и User.Messages ведёт сервер; повторная запись не дублируется
+	AddUserPost(ctx context.Context, userID string, post UserPost) error
+	RemoveUserPost(ctx context.Context, userID, postID string) error
+	AddUserMessage(ctx context.Context, userID string, message UserMessage) error
+	// Удаляет пользователя одной транзакцией вместе с его постами, лайками
+	// (счётчики постов уменьшаются), подписками, закладками и отзывает сессии
+	DeleteUser(ctx context.Context, id primitive.ObjectID) error
+	// false, если подписка уже была
+	Follow(ctx context.Context, follower, target User) (bool, error)
+	// false, если подписки не было
+	Unfollow(ctx context.Context, followerID, targetID primitive.ObjectID) (bool, error)
+	// Подписки пользователей (они — Follower)
+	ListFollowing(ctx context.Context, userIDs []string) ([]Follow, error)
+	// Подписчики пользователей (они — Followee)
+	ListFollowers(ctx context.Context, userIDs []string) ([]Follow, error)
+	// false, если блокировка уже была
+	Block(ctx context.Context, blocker, target User) (bool, error)
+	// false, если блокировки не было
+	Unblock(ctx context.Context, blockerID, targetID primitive.ObjectID) (bool, error)
+	// Блокировки, где пользователь блокирует или заблокирован
+	ListBlocks(ctx context.Context, userID string) ([]Block, error)
+}
+
+type PostStore interface {
+	CreatePost(ctx context.Context, post Post) error
+	ListPosts(ctx context.Context, page Page) ([]Post, error)
+	GetPost(ctx context.Context, id primitive.ObjectID) (Post, error)
+	// Существующие посты из списка; отсутствующие id пропускаются
+	ListPostsByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Post, error)
+	// Посты с хештегом tag
+	ListPostsByHashtag(ctx context.Context, tag string, page Page) ([]Post, error)
+	// До limit хештегов постов, созданных в [since, now), по убыванию
+	// суммы весов постов; вес убывает вдвое каждые halfLife
+	TrendingHashtags(ctx context.Context, since, now time.Time, halfLife time.Duration, limit int) ([]Trend, error)
+	// Посты, в тексте которых или в комментариях к которым упомянут пользователь
+	ListMentions(ctx context.Context, userID string, page Page) ([]Post, error)
+	// Посты авторов, включая их репосты
+	ListTimeline(ctx context.Context, authors []string, page Page) ([]Post, error)
+	// Записывает из updates только поля fields (имена в базе)
+	UpdatePost(ctx context.Context, id primitive.ObjectID, updates Post, fields []string) (Post, error)
+	// Удаляет пост вместе с его репостами, лайками и закладками (цитаты остаются)
+	DeletePost(ctx context.Context, id primitive.ObjectID) error
+	// Сохраняет репост или цитату и увеличивает счётчик у оригинала.
+	// Для репоста false, если автор уже репостнул этот пост.
+	CreateRepost(ctx context.Context, post Post) (bool, error)
+	// Удаляет репост или цитату и уменьшает счётчик у оригинала
+	DeleteRepost(ctx context.Context, post Post) error
+	GetRepost(ctx context.Context, author string, originalID primitive.ObjectID) (Post, error)
+	// Репосты перечисленных постов
+	ListRepostsOf(ctx context.Context, postIDs []string) ([]Post, error)
+	// Репосты, сделанные перечисленными авторами
+	ListRepostsBy(ctx context.Context, authors []string) ([]Post, error)
+	// Сохраняет лайк и увеличивает Post.Likes ровно один раз.
+	// false, если лайк уже стоял.
+	LikePost(ctx context.Context, user User, post Post) (bool, error)
+	// false, если лайка не было
+	UnlikePost(ctx context.Context, userID, postID primitive.ObjectID) (bool, error)
+	// Лайки поста, от новых к старым
+	ListLikes(ctx context.Context, postID primitive.ObjectID, page Page) ([]LikePost, error)
+	// Все лайки перечисленных пользователей
+	ListUserLikes(ctx context.Context, userIDs []string) ([]LikePost, error)
+	// false, если закладка уже была
+	BookmarkPost(ctx context.Context, user User, post Post) (bool, error)
+	// false, если закладки не было
+	UnbookmarkPost(ctx context.Context, userID, postID primitive.ObjectID) (bool, error)
+	ListBookmarks(ctx context.Context, userID primitive.ObjectID, page Page) ([]Bookmark, error)
+	AddComment(ctx context.Context, postID primitive.ObjectID, comment Comment) error
+	// Сохраняет текст, упоминания и дату правки комментария comment.ID;
+	// errNotFound, если поста или комментария нет
+	UpdateComment(ctx context.Context, postID primitive.ObjectID, comment Comment) error
+	DeleteComments(ctx context.Context, postID primitive.ObjectID, commentIDs []primitive.ObjectID) error
+}
+
+type ChatStore interface {
+	CreateChat(ctx context.Context, chat Chat) error
+	ListChats(ctx context.Context, page Page) ([]Chat, error)
+	ListChatsByIDD(ctx context.Context, idd string, page Page) ([]Chat, error)
+	// Чаты из перечисленных переписок
+	ListChatsByIDDs(ctx context.Context, idds []string, page Page) ([]Chat, error)
+	// Переписки, в которые писал автор
+	ListChatIDDs(ctx context.Context, author string) ([]string, error)
+}
+
+type MessageStore interface {
+	CreateMessage(ctx context.Context, message Message) error
+	ListMessages(ctx context.Context, page Page) ([]Message, error)
+	// Сообщения, где пользователь отправитель или получатель
+	ListUserMessages(ctx context.Context, userID string, page Page) ([]Message, error)
+	GetMessage(ctx context.Context, id primitive.ObjectID) (Message, error)
+	GetMessageByIDField(ctx context.Context, id string) (Message, error)
+	// Записывает из updates только поля fields (имена в базе)
+	UpdateMessage(ctx context.Context, id primitive.ObjectID, updates Message, fields []string) (Message, error)
+	DeleteMessage(ctx context.Context, id primitive.ObjectID) error
+}
+
+type NoticeStore interface {
+	CreateNotice(ctx context.Context, notice Notice) error
+	// Создаёт уведомление, если уведомления того же типа пользователю
+	// о том же посте и комментарии ещё нет. false, если оно уже было.
+	CreateNoticeOnce(ctx context.Context, notice Notice) (bool, error)
+	ListNotices(ctx context.Context, page Page) ([]Notice, error)
+	GetNotice(ctx context.Context, id primitive.ObjectID) (Notice, error)
+	// Записывает из updates только поля fields (имена в базе)
+	UpdateNotice(ctx context.Context, id primitive.ObjectID, updates Notice, fields []string) (Notice, error)
+	DeleteNotice(ctx context.Context, id primitive.ObjectID) error
+}
+
+type SessionStore interface {
+	CreateSession(ctx context.Context, session Session) error
+	// Активная (не отозванная и не истёкшая) сессия
+	GetActiveSession(ctx context.Context, id primitive.ObjectID) (Session, error)
+	// Заменяет хеш refresh-токена сессии id; errNotFound, если сессия
+	// не активна или её текущий хеш не oldHash
+	// Старый хеш сохраняется в PreviousHashes.
+	RotateSession(ctx context.Context, id primitive.ObjectID, oldHash, newHash string) (Session, error)
+	// Отзывает сессию id, если hash — один из её обменянных refresh-токенов.
+	// false, если такого хеша у сессии нет.
+	RevokeReusedSession(ctx context.Context, id primitive.ObjectID, hash string) (bool, error)
+	RevokeSession(ctx context.Context, id primitive.ObjectID) error
+	RevokeUserSessions(ctx context.Context, userID string) error
+}
+
+// Полнотекстовый поиск. mongoStore использует текстовые индексы MongoDB,
+// memoryStore — простой поиск по словам; другой движок подключается
+// реализацией этого интерфейса. При сортировке по релевантности
+// page.After — последний элемент предыдущей страницы в порядке выдачи.
+// Встроенный индекс на Bleve для тестов отложен: зависимость пока не
+// подключена, тесты идут через memoryStore.
+type SearchIndex interface {
+	SearchPosts(ctx context.Context, query SearchQuery, page Page) ([]Post, error)
+	SearchUsers(ctx context.Context, query SearchQuery, page Page) ([]User, error)
+}
+
+// Store объединяет все хранилища; реализуется mongoStore и memoryStore
+type Store interface {
+	UserStore
+	PostStore
+	ChatStore
+	MessageStore
+	NoticeStore
+	SessionStore
+	SearchIndex
+}
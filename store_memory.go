@@ -0,0 +1,1037 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Хранилище в памяти для тестов и локальной разработки без MongoDB
+type memoryStore struct {
+	mu       sync.RWMutex
+	users    map[primitive.ObjectID]User
+	posts    map[primitive.ObjectID]Post
+	chats    map[primitive.ObjectID]Chat
+	messages map[primitive.ObjectID]Message
+	notices  map[primitive.ObjectID]Notice
+	sessions map[primitive.ObjectID]Session
+
+	follows   map[primitive.ObjectID]Follow
+	likes     map[primitive.ObjectID]LikePost
+	bookmarks map[primitive.ObjectID]Bookmark
+	blocks    map[primitive.ObjectID]Block
+
+	handleHistory map[primitive.ObjectID]HandleChange
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		users:    map[primitive.ObjectID]User{},
+		posts:    map[primitive.ObjectID]Post{},
+		chats:    map[primitive.ObjectID]Chat{},
+		messages: map[primitive.ObjectID]Message{},
+		notices:  map[primitive.ObjectID]Notice{},
+		sessions: map[primitive.ObjectID]Session{},
+
+		follows:   map[primitive.ObjectID]Follow{},
+		likes:     map[primitive.ObjectID]LikePost{},
+		bookmarks: map[primitive.ObjectID]Bookmark{},
+		blocks:    map[primitive.ObjectID]Block{},
+
+		handleHistory: map[primitive.ObjectID]HandleChange{},
+	}
+}
+
+// Значения, отсортированные по _id (как естественный порядок вставки в MongoDB)
+func sortedValues[T any](m map[primitive.ObjectID]T, keep func(T) bool) []T {
+	ids := make([]primitive.ObjectID, 0, len(m))
+	for id, v := range m {
+		if keep == nil || keep(v) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return bytes.Compare(ids[i][:], ids[j][:]) < 0 })
+
+	items := make([]T, 0, len(ids))
+	for _, id := range ids {
+		items = append(items, m[id])
+	}
+	return items
+}
+
+// Страница по убыванию _id, как findPage у mongoStore
+func pageValues[T any](m map[primitive.ObjectID]T, keep func(T) bool, page Page) []T {
+	ids := make([]primitive.ObjectID, 0, len(m))
+	for id, v := range m {
+		if keep != nil && !keep(v) {
+			continue
+		}
+		if !page.After.IsZero() && bytes.Compare(id[:], page.After[:]) >= 0 {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return bytes.Compare(ids[i][:], ids[j][:]) > 0 })
+	if len(ids) > page.fetchLimit() {
+		ids = ids[:page.fetchLimit()]
+	}
+
+	items := make([]T, 0, len(ids))
+	for _, id := range ids {
+		items = append(items, m[id])
+	}
+	return items
+}
+
+func findValue[T any](m map[primitive.ObjectID]T, match func(T) bool) (T, error) {
+	for _, v := range sortedValues(m, match) {
+		return v, nil
+	}
+	var zero T
+	return zero, errNotFound
+}
+
+func getValue[T any](m map[primitive.ObjectID]T, id primitive.ObjectID) (T, error) {
+	v, ok := m[id]
+	if !ok {
+		return v, errNotFound
+	}
+	return v, nil
+}
+
+func deleteValue[T any](m map[primitive.ObjectID]T, id primitive.ObjectID) error {
+	if _, ok := m[id]; !ok {
+		return errNotFound
+	}
+	delete(m, id)
+	return nil
+}
+
+// Копирует из src в dst поля с bson-именами из fields, как $set в MongoDB
+func copyFields[T any](dst *T, src T, fields []string) {
+	d, v := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src)
+	for i := 0; i < d.NumField(); i++ {
+		name, _, _ := strings.Cut(d.Type().Field(i).Tag.Get("bson"), ",")
+		if slices.Contains(fields, name) {
+			d.Field(i).Set(v.Field(i))
+		}
+	}
+}
+
+// --- Users ---
+
+func (s *memoryStore) CreateUser(ctx context.Context, user User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.userConflict(user) {
+		return errConflict
+	}
+	s.users[user.ID] = user
+	return nil
+}
+
+func (s *memoryStore) UpsertGoogleUser(ctx context.Context, user User) (User, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.GoogleID == user.GoogleID {
+			return u, false, nil
+		}
+	}
+	if s.userConflict(user) {
+		return User{}, false, errConflict
+	}
+	s.users[user.ID] = user
+	return user, true, nil
+}
+
+// Повторяет уникальные индексы users по email, googleId и handle
+func (s *memoryStore) userConflict(user User) bool {
+	for id, u := range s.users {
+		if id != user.ID && (u.Email == user.Email || u.GoogleID == user.GoogleID || user.Handle != "" && u.Handle == user.Handle) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryStore) ListUsers(ctx context.Context, page Page) ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return pageValues(s.users, nil, page), nil
+}
+
+func (s *memoryStore) GetUser(ctx context.Context, id primitive.ObjectID) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return getValue(s.users, id)
+}
+
+func (s *memoryStore) GetUserByGoogleID(ctx context.Context, googleID string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return findValue(s.users, func(u User) bool { return u.GoogleID == googleID })
+}
+
+func (s *memoryStore) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return findValue(s.users, func(u User) bool { return u.Email == email })
+}
+
+func (s *memoryStore) GetUserByHandle(ctx context.Context, handle string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return findValue(s.users, func(u User) bool { return u.Handle != "" && u.Handle == handle })
+}
+
+func (s *memoryStore) ChangeHandle(ctx context.Context, change HandleChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	userID, err := primitive.ObjectIDFromHex(change.User)
+	if err != nil {
+		return errNotFound
+	}
+	user, ok := s.users[userID]
+	if !ok || user.Handle != change.Old {
+		return errHandleChanged
+	}
+	user.Handle = change.New
+	if s.userConflict(user) {
+		return errConflict
+	}
+	s.users[userID] = user
+	s.handleHistory[change.ID] = change
+	return nil
+}
+
+func (s *memoryStore) ListHandleChanges(ctx context.Context, userID string) ([]HandleChange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	changes := sortedValues(s.handleHistory, func(c HandleChange) bool { return c.User == userID })
+	slices.Reverse(changes)
+	return changes, nil
+}
+
+func (s *memoryStore) GetLastHandleChange(ctx context.Context, handle string) (HandleChange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	changes := sortedValues(s.handleHistory, func(c HandleChange) bool { return c.Old == handle })
+	if len(changes) == 0 {
+		return HandleChange{}, errNotFound
+	}
+	return changes[len(changes)-1], nil
+}
+
+func (s *memoryStore) ListUsersByHandles(ctx context.Context, handles []string) ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedValues(s.users, func(u User) bool { return u.Handle != "" && slices.Contains(handles, u.Handle) }), nil
+}
+
+func (s *memoryStore) ListUsersByIDs(ctx context.Context, ids []primitive.ObjectID) ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var users []User
+	for _, id := range ids {
+		if u, ok := s.users[id]; ok {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+
+func (s *memoryStore) UpdateUser(ctx context.Context, id primitive.ObjectID, updates User, fields []string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return User{}, errNotFound
+	}
+	copyFields(&user, updates, fields)
+	if s.userConflict(user) {
+		return User{}, errConflict
+	}
+	s.users[id] = user
+	return user, nil
+}
+
+func (s *memoryStore) UpdateProfile(ctx context.Context, id primitive.ObjectID, profile UserProfile) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return User{}, errNotFound
+	}
+	user.Profile = profile
+	s.users[id] = user
+	return user, nil
+}
+
+// Применяет change к пользователю userID под блокировкой
+func (s *memoryStore) updateUserLists(userID string, change func(*User)) error {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return errNotFound
+	}
+	change(&user)
+	s.users[id] = user
+	return nil
+}
+
+func (s *memoryStore) AddUserPost(ctx context.Context, userID string, post UserPost) error {
+	return s.updateUserLists(userID, func(u *User) {
+		if !slices.Contains(u.Posts, post) {
+			u.Posts = append(slices.Clone(u.Posts), post)
+		}
+	})
+}
+
+func (s *memoryStore) RemoveUserPost(ctx context.Context, userID, postID string) error {
+	return s.updateUserLists(userID, func(u *User) {
+		u.Posts = slices.DeleteFunc(slices.Clone(u.Posts), func(p UserPost) bool { return p.Post == postID })
+	})
+}
+
+func (s *memoryStore) AddUserMessage(ctx context.Context, userID string, message UserMessage) error {
+	return s.updateUserLists(userID, func(u *User) {
+		if !slices.Contains(u.Messages, message) {
+			u.Messages = append(slices.Clone(u.Messages), message)
+		}
+	})
+}
+
+func (s *memoryStore) DeleteUser(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	uid := id.Hex()
+	if err := deleteValue(s.users, id); err != nil {
+		return err
+	}
+
+	for likeID, like := range s.likes {
+		if like.User != uid {
+			continue
+		}
+		delete(s.likes, likeID)
+		if postID, err := primitive.ObjectIDFromHex(like.Post); err == nil {
+			if post, ok := s.posts[postID]; ok && post.Likes > 0 {
+				post.Likes--
+				s.posts[postID] = post
+			}
+		}
+	}
+
+	var ownIDs []string
+	for postID, post := range s.posts {
+		if post.Author != uid {
+			continue
+		}
+		ownIDs = append(ownIDs, postID.Hex())
+		delete(s.posts, postID)
+		if originalID, err := primitive.ObjectIDFromHex(post.OriginalID()); err == nil {
+			if original, ok := s.posts[originalID]; ok {
+				if post.RepostOf != "" && original.RepostCount > 0 {
+					original.RepostCount--
+				} else if post.QuoteOf != "" && original.QuoteCount > 0 {
+					original.QuoteCount--
+				}
+				s.posts[originalID] = original
+			}
+		}
+	}
+	maps.DeleteFunc(s.posts, func(_ primitive.ObjectID, p Post) bool { return slices.Contains(ownIDs, p.RepostOf) })
+	maps.DeleteFunc(s.likes, func(_ primitive.ObjectID, l LikePost) bool { return slices.Contains(ownIDs, l.Post) })
+
+	maps.DeleteFunc(s.bookmarks, func(_ primitive.ObjectID, b Bookmark) bool {
+		return b.User == uid || slices.Contains(ownIDs, b.Post)
+	})
+	maps.DeleteFunc(s.follows, func(_ primitive.ObjectID, f Follow) bool {
+		return f.Follower == uid || f.Followee == uid
+	})
+	maps.DeleteFunc(s.blocks, func(_ primitive.ObjectID, b Block) bool {
+		return b.Blocker == uid || b.Blocked == uid
+	})
+	maps.DeleteFunc(s.handleHistory, func(_ primitive.ObjectID, c HandleChange) bool { return c.User == uid })
+	for sessionID, session := range s.sessions {
+		if session.User == uid {
+			session.Revoked = true
+			s.sessions[sessionID] = session
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) Follow(ctx context.Context, follower, target User) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	follow := Follow{ID: primitive.NewObjectID(), Follower: follower.ID.Hex(), Followee: target.ID.Hex(), CreateDate: timestampNow()}
+	if _, err := findValue(s.follows, func(f Follow) bool {
+		return f.Follower == follow.Follower && f.Followee == follow.Followee
+	}); err == nil {
+		return false, nil
+	}
+	s.follows[follow.ID] = follow
+	return true, nil
+}
+
+func (s *memoryStore) Unfollow(ctx context.Context, followerID, targetID primitive.ObjectID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := findValue(s.follows, func(f Follow) bool {
+		return f.Follower == followerID.Hex() && f.Followee == targetID.Hex()
+	})
+	if err != nil {
+		return false, nil
+	}
+	delete(s.follows, f.ID)
+	return true, nil
+}
+
+func (s *memoryStore) ListFollowing(ctx context.Context, userIDs []string) ([]Follow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedValues(s.follows, func(f Follow) bool { return slices.Contains(userIDs, f.Follower) }), nil
+}
+
+func (s *memoryStore) ListFollowers(ctx context.Context, userIDs []string) ([]Follow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedValues(s.follows, func(f Follow) bool { return slices.Contains(userIDs, f.Followee) }), nil
+}
+
+func (s *memoryStore) Block(ctx context.Context, blocker, target User) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	block := Block{ID: primitive.NewObjectID(), Blocker: blocker.ID.Hex(), Blocked: target.ID.Hex(), CreateDate: timestampNow()}
+	if _, err := findValue(s.blocks, func(b Block) bool {
+		return b.Blocker == block.Blocker && b.Blocked == block.Blocked
+	}); err == nil {
+		return false, nil
+	}
+	s.blocks[block.ID] = block
+	return true, nil
+}
+
+func (s *memoryStore) Unblock(ctx context.Context, blockerID, targetID primitive.ObjectID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := findValue(s.blocks, func(b Block) bool {
+		return b.Blocker == blockerID.Hex() && b.Blocked == targetID.Hex()
+	})
+	if err != nil {
+		return false, nil
+	}
+	delete(s.blocks, b.ID)
+	return true, nil
+}
+
+func (s *memoryStore) ListBlocks(ctx context.Context, userID string) ([]Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedValues(s.blocks, func(b Block) bool { return b.Blocker == userID || b.Blocked == userID }), nil
+}
+
+// --- Posts ---
+
+func (s *memoryStore) CreatePost(ctx context.Context, post Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.posts[post.ID] = post
+	return nil
+}
+
+func (s *memoryStore) ListPosts(ctx context.Context, page Page) ([]Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return pageValues(s.posts, nil, page), nil
+}
+
+func (s *memoryStore) GetPost(ctx context.Context, id primitive.ObjectID) (Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return getValue(s.posts, id)
+}
+
+func (s *memoryStore) ListTimeline(ctx context.Context, authors []string, page Page) ([]Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return pageValues(s.posts, func(p Post) bool { return slices.Contains(authors, p.Author) }, page), nil
+}
+
+func (s *memoryStore) ListMentions(ctx context.Context, userID string, page Page) ([]Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	mentioned := func(m Mention) bool { return m.User == userID }
+	return pageValues(s.posts, func(p Post) bool {
+		return slices.ContainsFunc(p.Mentions, mentioned) ||
+			slices.ContainsFunc(p.Comments, func(c Comment) bool { return slices.ContainsFunc(c.Mentions, mentioned) })
+	}, page), nil
+}
+
+func (s *memoryStore) ListPostsByHashtag(ctx context.Context, tag string, page Page) ([]Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return pageValues(s.posts, func(p Post) bool { return slices.Contains(p.Hashtags, tag) }, page), nil
+}
+
+func (s *memoryStore) TrendingHashtags(ctx context.Context, since, now time.Time, halfLife time.Duration, limit int) ([]Trend, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	byTag := map[string]*Trend{}
+	for _, p := range s.posts {
+		if p.CreateDate.Before(since) || !p.CreateDate.Before(now) {
+			continue
+		}
+		for _, tag := range p.Hashtags {
+			t, ok := byTag[tag]
+			if !ok {
+				t = &Trend{Tag: tag}
+				byTag[tag] = t
+			}
+			t.Posts++
+			t.Score += trendWeight(now.Sub(p.CreateDate.Time), halfLife)
+		}
+	}
+
+	trends := make([]Trend, 0, len(byTag))
+	for _, t := range byTag {
+		trends = append(trends, *t)
+	}
+	sort.Slice(trends, func(i, j int) bool {
+		if trends[i].Score != trends[j].Score {
+			return trends[i].Score > trends[j].Score
+		}
+		return trends[i].Tag < trends[j].Tag
+	})
+	if len(trends) > limit {
+		trends = trends[:limit]
+	}
+	return trends, nil
+}
+
+func (s *memoryStore) ListPostsByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var posts []Post
+	for _, id := range ids {
+		if p, ok := s.posts[id]; ok {
+			posts = append(posts, p)
+		}
+	}
+	return posts, nil
+}
+
+func (s *memoryStore) UpdatePost(ctx context.Context, id primitive.ObjectID, updates Post, fields []string) (Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	post, ok := s.posts[id]
+	if !ok {
+		return Post{}, errNotFound
+	}
+	copyFields(&post, updates, fields)
+	s.posts[id] = post
+	return post, nil
+}
+
+func (s *memoryStore) DeletePost(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := deleteValue(s.posts, id); err != nil {
+		return err
+	}
+	maps.DeleteFunc(s.posts, func(_ primitive.ObjectID, p Post) bool { return p.RepostOf == id.Hex() })
+	maps.DeleteFunc(s.likes, func(_ primitive.ObjectID, l LikePost) bool { return l.Post == id.Hex() })
+	maps.DeleteFunc(s.bookmarks, func(_ primitive.ObjectID, b Bookmark) bool { return b.Post == id.Hex() })
+	return nil
+}
+
+func (s *memoryStore) CreateRepost(ctx context.Context, post Post) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	originalID, err := primitive.ObjectIDFromHex(post.OriginalID())
+	if err != nil {
+		return false, errNotFound
+	}
+	original, ok := s.posts[originalID]
+	if !ok {
+		return false, errNotFound
+	}
+
+	if post.RepostOf != "" {
+		for _, p := range s.posts {
+			if p.Author == post.Author && p.RepostOf == post.RepostOf {
+				return false, nil
+			}
+		}
+		original.RepostCount++
+	} else {
+		original.QuoteCount++
+	}
+	s.posts[post.ID] = post
+	s.posts[originalID] = original
+	return true, nil
+}
+
+func (s *memoryStore) DeleteRepost(ctx context.Context, post Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := deleteValue(s.posts, post.ID); err != nil {
+		return err
+	}
+	originalID, _ := primitive.ObjectIDFromHex(post.OriginalID())
+	if original, ok := s.posts[originalID]; ok {
+		if post.RepostOf != "" && original.RepostCount > 0 {
+			original.RepostCount--
+		} else if post.QuoteOf != "" && original.QuoteCount > 0 {
+			original.QuoteCount--
+		}
+		s.posts[originalID] = original
+	}
+	return nil
+}
+
+func (s *memoryStore) GetRepost(ctx context.Context, author string, originalID primitive.ObjectID) (Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return findValue(s.posts, func(p Post) bool { return p.Author == author && p.RepostOf == originalID.Hex() })
+}
+
+func (s *memoryStore) ListRepostsOf(ctx context.Context, postIDs []string) ([]Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedValues(s.posts, func(p Post) bool { return p.RepostOf != "" && slices.Contains(postIDs, p.RepostOf) }), nil
+}
+
+func (s *memoryStore) ListRepostsBy(ctx context.Context, authors []string) ([]Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedValues(s.posts, func(p Post) bool { return p.RepostOf != "" && slices.Contains(authors, p.Author) }), nil
+}
+
+func (s *memoryStore) LikePost(ctx context.Context, user User, post Post) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.posts[post.ID]
+	if !ok {
+		return false, errNotFound
+	}
+	if _, err := findValue(s.likes, func(l LikePost) bool {
+		return l.User == user.ID.Hex() && l.Post == post.ID.Hex()
+	}); err == nil {
+		return false, nil
+	}
+
+	like := LikePost{ID: primitive.NewObjectID(), User: user.ID.Hex(), Post: post.ID.Hex(), Author: post.Author, State: true, CreateDate: timestampNow()}
+	s.likes[like.ID] = like
+	p.Likes++
+	s.posts[p.ID] = p
+	return true, nil
+}
+
+func (s *memoryStore) UnlikePost(ctx context.Context, userID, postID primitive.ObjectID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	like, err := findValue(s.likes, func(l LikePost) bool {
+		return l.User == userID.Hex() && l.Post == postID.Hex()
+	})
+	if err != nil {
+		return false, nil
+	}
+
+	delete(s.likes, like.ID)
+	if p, ok := s.posts[postID]; ok && p.Likes > 0 {
+		p.Likes--
+		s.posts[postID] = p
+	}
+	return true, nil
+}
+
+func (s *memoryStore) ListLikes(ctx context.Context, postID primitive.ObjectID, page Page) ([]LikePost, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return pageValues(s.likes, func(l LikePost) bool { return l.Post == postID.Hex() }, page), nil
+}
+
+func (s *memoryStore) ListUserLikes(ctx context.Context, userIDs []string) ([]LikePost, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedValues(s.likes, func(l LikePost) bool { return slices.Contains(userIDs, l.User) }), nil
+}
+
+func (s *memoryStore) BookmarkPost(ctx context.Context, user User, post Post) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := findValue(s.bookmarks, func(b Bookmark) bool {
+		return b.User == user.ID.Hex() && b.Post == post.ID.Hex()
+	}); err == nil {
+		return false, nil
+	}
+
+	bookmark := Bookmark{ID: primitive.NewObjectID(), User: user.ID.Hex(), Post: post.ID.Hex(), Author: post.Author, State: true, CreateDate: timestampNow()}
+	s.bookmarks[bookmark.ID] = bookmark
+	return true, nil
+}
+
+func (s *memoryStore) UnbookmarkPost(ctx context.Context, userID, postID primitive.ObjectID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bookmark, err := findValue(s.bookmarks, func(b Bookmark) bool {
+		return b.User == userID.Hex() && b.Post == postID.Hex()
+	})
+	if err != nil {
+		return false, nil
+	}
+	delete(s.bookmarks, bookmark.ID)
+	return true, nil
+}
+
+func (s *memoryStore) ListBookmarks(ctx context.Context, userID primitive.ObjectID, page Page) ([]Bookmark, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return pageValues(s.bookmarks, func(b Bookmark) bool { return b.User == userID.Hex() }, page), nil
+}
+
+func (s *memoryStore) AddComment(ctx context.Context, postID primitive.ObjectID, comment Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.posts[postID]
+	if !ok {
+		return errNotFound
+	}
+	p.Comments = append(slices.Clone(p.Comments), comment)
+	s.posts[postID] = p
+	return nil
+}
+
+func (s *memoryStore) UpdateComment(ctx context.Context, postID primitive.ObjectID, comment Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.posts[postID]
+	if !ok {
+		return errNotFound
+	}
+	i := slices.IndexFunc(p.Comments, func(c Comment) bool { return c.ID == comment.ID })
+	if i < 0 {
+		return errNotFound
+	}
+	p.Comments = slices.Clone(p.Comments)
+	p.Comments[i].Text = comment.Text
+	p.Comments[i].Mentions = comment.Mentions
+	p.Comments[i].EditDate = comment.EditDate
+	s.posts[postID] = p
+	return nil
+}
+
+func (s *memoryStore) DeleteComments(ctx context.Context, postID primitive.ObjectID, commentIDs []primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.posts[postID]
+	if !ok {
+		return errNotFound
+	}
+	p.Comments = slices.DeleteFunc(slices.Clone(p.Comments), func(c Comment) bool {
+		return slices.Contains(commentIDs, c.ID)
+	})
+	s.posts[postID] = p
+	return nil
+}
+
+// --- Chats ---
+
+func (s *memoryStore) CreateChat(ctx context.Context, chat Chat) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chats[chat.ID] = chat
+	return nil
+}
+
+func (s *memoryStore) ListChats(ctx context.Context, page Page) ([]Chat, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return pageValues(s.chats, nil, page), nil
+}
+
+func (s *memoryStore) ListChatsByIDD(ctx context.Context, idd string, page Page) ([]Chat, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return pageValues(s.chats, func(c Chat) bool { return c.IDD == idd }, page), nil
+}
+
+func (s *memoryStore) ListChatsByIDDs(ctx context.Context, idds []string, page Page) ([]Chat, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return pageValues(s.chats, func(c Chat) bool { return slices.Contains(idds, c.IDD) }, page), nil
+}
+
+func (s *memoryStore) ListChatIDDs(ctx context.Context, author string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var idds []string
+	for _, c := range s.chats {
+		if c.Author == author && !slices.Contains(idds, c.IDD) {
+			idds = append(idds, c.IDD)
+		}
+	}
+	return idds, nil
+}
+
+// --- Messages ---
+
+func (s *memoryStore) CreateMessage(ctx context.Context, message Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages[message.ID] = message
+	return nil
+}
+
+func (s *memoryStore) ListMessages(ctx context.Context, page Page) ([]Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return pageValues(s.messages, nil, page), nil
+}
+
+func (s *memoryStore) ListUserMessages(ctx context.Context, userID string, page Page) ([]Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return pageValues(s.messages, func(m Message) bool { return m.Sender == userID || m.Receiver == userID }, page), nil
+}
+
+func (s *memoryStore) GetMessage(ctx context.Context, id primitive.ObjectID) (Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return getValue(s.messages, id)
+}
+
+func (s *memoryStore) GetMessageByIDField(ctx context.Context, id string) (Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return findValue(s.messages, func(m Message) bool { return m.IDField == id })
+}
+
+func (s *memoryStore) UpdateMessage(ctx context.Context, id primitive.ObjectID, updates Message, fields []string) (Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	message, ok := s.messages[id]
+	if !ok {
+		return Message{}, errNotFound
+	}
+	copyFields(&message, updates, fields)
+	s.messages[id] = message
+	return message, nil
+}
+
+func (s *memoryStore) DeleteMessage(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return deleteValue(s.messages, id)
+}
+
+// --- Notices ---
+
+func (s *memoryStore) CreateNotice(ctx context.Context, notice Notice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notices[notice.ID] = notice
+	return nil
+}
+
+func (s *memoryStore) CreateNoticeOnce(ctx context.Context, notice Notice) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, n := range s.notices {
+		if n.User == notice.User && n.Type == notice.Type && n.Post == notice.Post && n.Comment == notice.Comment {
+			return false, nil
+		}
+	}
+	s.notices[notice.ID] = notice
+	return true, nil
+}
+
+func (s *memoryStore) ListNotices(ctx context.Context, page Page) ([]Notice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return pageValues(s.notices, nil, page), nil
+}
+
+func (s *memoryStore) GetNotice(ctx context.Context, id primitive.ObjectID) (Notice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return getValue(s.notices, id)
+}
+
+func (s *memoryStore) UpdateNotice(ctx context.Context, id primitive.ObjectID, updates Notice, fields []string) (Notice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	notice, ok := s.notices[id]
+	if !ok {
+		return Notice{}, errNotFound
+	}
+	copyFields(&notice, updates, fields)
+	s.notices[id] = notice
+	return notice, nil
+}
+
+func (s *memoryStore) DeleteNotice(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return deleteValue(s.notices, id)
+}
+
+// --- Sessions ---
+
+func (s *memoryStore) CreateSession(ctx context.Context, session Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session.ID] = session
+	return nil
+}
+
+func (s *memoryStore) GetActiveSession(ctx context.Context, id primitive.ObjectID) (Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	session, ok := s.sessions[id]
+	if !ok || session.Revoked || !session.ExpiresAt.After(time.Now()) {
+		return Session{}, errNotFound
+	}
+	return session, nil
+}
+
+func (s *memoryStore) RotateSession(ctx context.Context, id primitive.ObjectID, oldHash, newHash string) (Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok || session.RefreshHash != oldHash || session.Revoked || !session.ExpiresAt.After(time.Now()) {
+		return Session{}, errNotFound
+	}
+	session.PreviousHashes = append(slices.Clone(session.PreviousHashes), oldHash)
+	session.RefreshHash = newHash
+	s.sessions[id] = session
+	return session, nil
+}
+
+func (s *memoryStore) RevokeReusedSession(ctx context.Context, id primitive.ObjectID, hash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok || !slices.Contains(session.PreviousHashes, hash) {
+		return false, nil
+	}
+	session.Revoked = true
+	s.sessions[id] = session
+	return true, nil
+}
+
+func (s *memoryStore) RevokeSession(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, ok := s.sessions[id]; ok {
+		session.Revoked = true
+		s.sessions[id] = session
+	}
+	return nil
+}
+
+func (s *memoryStore) RevokeUserSessions(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, session := range s.sessions {
+		if session.User == userID {
+			session.Revoked = true
+			s.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+// --- Search ---
+
+// Приблизительно повторяет $text: регистр не важен, слова сравниваются
+// целиком и без стемминга, _ — часть слова. Возвращает число совпавших
+// слов и фраз или -1, если документ не подходит.
+func searchScore(query SearchQuery, text string) int {
+	text = strings.ToLower(text)
+	for _, phrase := range query.Phrases {
+		if !strings.Contains(text, strings.ToLower(phrase)) {
+			return -1
+		}
+	}
+	words := strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' })
+	score := len(query.Phrases)
+	for _, term := range query.Terms {
+		for _, word := range words {
+			if word == strings.ToLower(term) {
+				score++
+			}
+		}
+	}
+	if score == 0 {
+		return -1
+	}
+	return score
+}
+
+// Поиск по значениям карты: по новизне — как pageValues, по релевантности —
+// по убыванию searchScore, при равенстве — по убыванию _id
+func searchValues[T any](m map[primitive.ObjectID]T, query SearchQuery, page Page, textOf func(T) string, keep func(T) bool, idOf func(T) primitive.ObjectID) []T {
+	match := func(v T) bool { return (keep == nil || keep(v)) && searchScore(query, textOf(v)) >= 0 }
+	if query.Sort == searchSortRecent {
+		return pageValues(m, match, page)
+	}
+
+	items := sortedValues(m, match)
+	slices.Reverse(items)
+	sort.SliceStable(items, func(i, j int) bool {
+		return searchScore(query, textOf(items[i])) > searchScore(query, textOf(items[j]))
+	})
+	if len(items) > maxSearchResults {
+		items = items[:maxSearchResults]
+	}
+	return pageAfter(items, page, idOf)
+}
+
+func (s *memoryStore) SearchPosts(ctx context.Context, query SearchQuery, page Page) ([]Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keep := func(p Post) bool {
+		return (query.Author == "" || p.Author == query.Author) &&
+			(query.Since.IsZero() || !p.CreateDate.Before(query.Since)) &&
+			(query.Until.IsZero() || p.CreateDate.Before(query.Until))
+	}
+	return searchValues(s.posts, query, page, func(p Post) string { return p.Text }, keep,
+		func(p Post) primitive.ObjectID { return p.ID }), nil
+}
+
+func (s *memoryStore) SearchUsers(ctx context.Context, query SearchQuery, page Page) ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return searchValues(s.users, query, page, func(u User) string { return u.Name + " " + u.Handle }, nil,
+		func(u User) primitive.ObjectID { return u.ID }), nil
+}
+
+var _ Store = (*memoryStore)(nil)
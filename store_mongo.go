@@ -0,0 +1,942 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Реализация хранилищ поверх MongoDB
+type mongoStore struct {
+	db *mongo.Database
+}
+
+func newMongoStore(db *mongo.Database) *mongoStore {
+	return &mongoStore{db: db}
+}
+
+func (s *mongoStore) collection(name string) *mongo.Collection {
+	return s.db.Collection(name)
+}
+
+// Выполняет fn в транзакции (нужен replica set, как в Atlas)
+func (s *mongoStore) withTransaction(ctx context.Context, fn func(sc mongo.SessionContext) error) error {
+	session, err := s.db.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// Переводит mongo.ErrNoDocuments в errNotFound
+func mongoErr(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return errNotFound
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("%w: %v", errConflict, err)
+	}
+	return err
+}
+
+func findAll[T any](ctx context.Context, coll *mongo.Collection, filter interface{}, opts ...*options.FindOptions) ([]T, error) {
+	cursor, err := coll.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var items []T
+	if err = cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Страница по убыванию _id, начиная после page.After
+func findPage[T any](ctx context.Context, coll *mongo.Collection, filter bson.M, page Page) ([]T, error) {
+	if !page.After.IsZero() {
+		filter["_id"] = bson.M{"$lt": page.After}
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetLimit(int64(page.fetchLimit()))
+	return findAll[T](ctx, coll, filter, opts)
+}
+
+func findOne[T any](ctx context.Context, coll *mongo.Collection, filter interface{}, opts ...*options.FindOneOptions) (T, error) {
+	var item T
+	err := coll.FindOne(ctx, filter, opts...).Decode(&item)
+	return item, mongoErr(err)
+}
+
+// Записывает в документ id только поля fields (имена в базе) из updates:
+// остальные поля документа могли измениться параллельно ($inc, $push).
+// Поле, которого нет в updates из-за omitempty, удаляется.
+func setFields[T any](ctx context.Context, coll *mongo.Collection, id primitive.ObjectID, updates T, fields []string) (T, error) {
+	var updated T
+	data, err := bson.Marshal(updates)
+	if err != nil {
+		return updated, err
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return updated, err
+	}
+	set, unset := bson.M{}, bson.M{}
+	for _, field := range fields {
+		if value, ok := doc[field]; ok {
+			set[field] = value
+		} else {
+			unset[field] = ""
+		}
+	}
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	if len(update) == 0 {
+		return findOne[T](ctx, coll, bson.M{"_id": id})
+	}
+	err = coll.FindOneAndUpdate(ctx, bson.M{"_id": id}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	return updated, mongoErr(err)
+}
+
+func deleteByID(ctx context.Context, coll *mongo.Collection, id primitive.ObjectID) error {
+	result, err := coll.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+// --- Users ---
+
+func (s *mongoStore) CreateUser(ctx context.Context, user User) error {
+	_, err := s.collection(collectionUser).InsertOne(ctx, user)
+	return mongoErr(err)
+}
+
+// Один запрос findAndModify с upsert: параллельные входы одного аккаунта
+// не создают дубликатов, уникальный индекс по email ловит чужой аккаунт.
+func (s *mongoStore) UpsertGoogleUser(ctx context.Context, user User) (User, bool, error) {
+	users := s.collection(collectionUser)
+	upsert := func() (User, error) {
+		var found User
+		err := users.FindOneAndUpdate(ctx,
+			bson.M{"googleId": user.GoogleID},
+			bson.M{"$setOnInsert": user},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&found)
+		return found, mongoErr(err)
+	}
+
+	found, err := upsert()
+	if errors.Is(err, errConflict) {
+		// Гонка двух upsert по одному googleId: второй получает duplicate key
+		// и при повторе находит уже созданный документ
+		found, err = upsert()
+	}
+	if err != nil {
+		return User{}, false, err
+	}
+	return found, found.ID == user.ID, nil
+}
+
+func (s *mongoStore) ListUsers(ctx context.Context, page Page) ([]User, error) {
+	return findPage[User](ctx, s.collection(collectionUser), bson.M{}, page)
+}
+
+func (s *mongoStore) GetUser(ctx context.Context, id primitive.ObjectID) (User, error) {
+	return findOne[User](ctx, s.collection(collectionUser), bson.M{"_id": id})
+}
+
+func (s *mongoStore) GetUserByGoogleID(ctx context.Context, googleID string) (User, error) {
+	return findOne[User](ctx, s.collection(collectionUser), bson.M{"googleId": googleID})
+}
+
+func (s *mongoStore) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	return findOne[User](ctx, s.collection(collectionUser), bson.M{"email": email})
+}
+
+func (s *mongoStore) GetUserByHandle(ctx context.Context, handle string) (User, error) {
+	return findOne[User](ctx, s.collection(collectionUser), bson.M{"handle": handle})
+}
+
+// Смена проверяет старый handle, поэтому из двух параллельных смен
+// проходит одна
+func (s *mongoStore) ChangeHandle(ctx context.Context, change HandleChange) error {
+	userID, err := primitive.ObjectIDFromHex(change.User)
+	if err != nil {
+		return errNotFound
+	}
+	old := interface{}(change.Old)
+	if change.Old == "" {
+		old = bson.M{"$exists": false}
+	}
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		result, err := s.collection(collectionUser).UpdateOne(sc,
+			bson.M{"_id": userID, "handle": old},
+			bson.M{"$set": bson.M{"handle": change.New}},
+		)
+		if err != nil {
+			return mongoErr(err)
+		}
+		if result.MatchedCount == 0 {
+			return errHandleChanged
+		}
+		_, err = s.collection(collectionHandleHistory).InsertOne(sc, change)
+		return err
+	})
+}
+
+func (s *mongoStore) ListHandleChanges(ctx context.Context, userID string) ([]HandleChange, error) {
+	return findAll[HandleChange](ctx, s.collection(collectionHandleHistory), bson.M{"user": userID},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}))
+}
+
+func (s *mongoStore) GetLastHandleChange(ctx context.Context, handle string) (HandleChange, error) {
+	return findOne[HandleChange](ctx, s.collection(collectionHandleHistory), bson.M{"old": handle},
+		options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}}))
+}
+
+func (s *mongoStore) ListUsersByHandles(ctx context.Context, handles []string) ([]User, error) {
+	if len(handles) == 0 {
+		return nil, nil
+	}
+	return findAll[User](ctx, s.collection(collectionUser), bson.M{"handle": bson.M{"$in": handles}})
+}
+
+func (s *mongoStore) ListUsersByIDs(ctx context.Context, ids []primitive.ObjectID) ([]User, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return findAll[User](ctx, s.collection(collectionUser), bson.M{"_id": bson.M{"$in": ids}})
+}
+
+func (s *mongoStore) UpdateUser(ctx context.Context, id primitive.ObjectID, updates User, fields []string) (User, error) {
+	return setFields(ctx, s.collection(collectionUser), id, updates, fields)
+}
+
+func (s *mongoStore) UpdateProfile(ctx context.Context, id primitive.ObjectID, profile UserProfile) (User, error) {
+	var updated User
+	err := s.collection(collectionUser).FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"profile": profile}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	return updated, mongoErr(err)
+}
+
+func (s *mongoStore) updateUserLists(ctx context.Context, userID string, update bson.M) error {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errNotFound
+	}
+	result, err := s.collection(collectionUser).UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+func (s *mongoStore) AddUserPost(ctx context.Context, userID string, post UserPost) error {
+	return s.updateUserLists(ctx, userID, bson.M{"$addToSet": bson.M{"posts": post}})
+}
+
+func (s *mongoStore) RemoveUserPost(ctx context.Context, userID, postID string) error {
+	return s.updateUserLists(ctx, userID, bson.M{"$pull": bson.M{"posts": bson.M{"post": postID}}})
+}
+
+func (s *mongoStore) AddUserMessage(ctx context.Context, userID string, message UserMessage) error {
+	return s.updateUserLists(ctx, userID, bson.M{"$addToSet": bson.M{"messages": message}})
+}
+
+// Подписки и блокировки в обе стороны, закладки и история handle удаляются вместе с пользователем;
+// лайки остаются, чтобы не пересчитывать Post.Likes.
+func (s *mongoStore) DeleteUser(ctx context.Context, id primitive.ObjectID) error {
+	uid := id.Hex()
+	posts, likes := s.collection(collectionPost), s.collection(collectionLike)
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		if err := deleteByID(sc, s.collection(collectionUser), id); err != nil {
+			return err
+		}
+
+		// Лайки пользователя снимаются вместе со счётчиками постов
+		liked, err := findAll[LikePost](sc, likes, bson.M{"user": uid})
+		if err != nil {
+			return err
+		}
+		likedIDs := make([]primitive.ObjectID, 0, len(liked))
+		for _, like := range liked {
+			if postID, err := primitive.ObjectIDFromHex(like.Post); err == nil {
+				likedIDs = append(likedIDs, postID)
+			}
+		}
+		if _, err := likes.DeleteMany(sc, bson.M{"user": uid}); err != nil {
+			return err
+		}
+		if _, err := posts.UpdateMany(sc,
+			bson.M{"_id": bson.M{"$in": likedIDs}, "likes": bson.M{"$gt": 0}},
+			bson.M{"$inc": bson.M{"likes": -1}},
+		); err != nil {
+			return err
+		}
+
+		// Посты пользователя: репосты и цитаты уменьшают счётчики оригиналов,
+		// у своих постов удаляются репосты, лайки и закладки (цитаты остаются)
+		own, err := findAll[Post](sc, posts, bson.M{"author": uid})
+		if err != nil {
+			return err
+		}
+		ownIDs := make([]string, 0, len(own))
+		for _, post := range own {
+			ownIDs = append(ownIDs, post.ID.Hex())
+			originalID, err := primitive.ObjectIDFromHex(post.OriginalID())
+			if err != nil {
+				continue
+			}
+			counter := repostCounter(post)
+			if _, err := posts.UpdateOne(sc,
+				bson.M{"_id": originalID, counter: bson.M{"$gt": 0}},
+				bson.M{"$inc": bson.M{counter: -1}},
+			); err != nil {
+				return err
+			}
+		}
+		if _, err := posts.DeleteMany(sc, bson.M{"$or": bson.A{
+			bson.M{"author": uid},
+			bson.M{"repostOf": bson.M{"$in": ownIDs}},
+		}}); err != nil {
+			return err
+		}
+		if _, err := likes.DeleteMany(sc, bson.M{"post": bson.M{"$in": ownIDs}}); err != nil {
+			return err
+		}
+
+		if _, err := s.collection(collectionBookmark).DeleteMany(sc, bson.M{"$or": bson.A{
+			bson.M{"user": uid},
+			bson.M{"post": bson.M{"$in": ownIDs}},
+		}}); err != nil {
+			return err
+		}
+		if _, err := s.collection(collectionFollow).DeleteMany(sc, bson.M{"$or": bson.A{
+			bson.M{"follower": uid},
+			bson.M{"followee": uid},
+		}}); err != nil {
+			return err
+		}
+		if _, err := s.collection(collectionBlock).DeleteMany(sc, bson.M{"$or": bson.A{
+			bson.M{"blocker": uid},
+			bson.M{"blocked": uid},
+		}}); err != nil {
+			return err
+		}
+		// Старые handle освобождаются сразу
+		if _, err := s.collection(collectionHandleHistory).DeleteMany(sc, bson.M{"user": uid}); err != nil {
+			return err
+		}
+		// Удалённый пользователь не должен оставаться залогиненным
+		return s.RevokeUserSessions(sc, uid)
+	})
+}
+
+func (s *mongoStore) Follow(ctx context.Context, follower, target User) (bool, error) {
+	follow := Follow{ID: primitive.NewObjectID(), Follower: follower.ID.Hex(), Followee: target.ID.Hex(), CreateDate: timestampNow()}
+	result, err := s.collection(collectionFollow).UpdateOne(ctx,
+		bson.M{"follower": follow.Follower, "followee": follow.Followee},
+		bson.M{"$setOnInsert": follow},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount > 0, nil
+}
+
+func (s *mongoStore) Unfollow(ctx context.Context, followerID, targetID primitive.ObjectID) (bool, error) {
+	result, err := s.collection(collectionFollow).DeleteOne(ctx, bson.M{"follower": followerID.Hex(), "followee": targetID.Hex()})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (s *mongoStore) ListFollowing(ctx context.Context, userIDs []string) ([]Follow, error) {
+	return findAll[Follow](ctx, s.collection(collectionFollow), bson.M{"follower": bson.M{"$in": userIDs}})
+}
+
+func (s *mongoStore) ListFollowers(ctx context.Context, userIDs []string) ([]Follow, error) {
+	return findAll[Follow](ctx, s.collection(collectionFollow), bson.M{"followee": bson.M{"$in": userIDs}})
+}
+
+func (s *mongoStore) Block(ctx context.Context, blocker, target User) (bool, error) {
+	block := Block{ID: primitive.NewObjectID(), Blocker: blocker.ID.Hex(), Blocked: target.ID.Hex(), CreateDate: timestampNow()}
+	result, err := s.collection(collectionBlock).UpdateOne(ctx,
+		bson.M{"blocker": block.Blocker, "blocked": block.Blocked},
+		bson.M{"$setOnInsert": block},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount > 0, nil
+}
+
+func (s *mongoStore) Unblock(ctx context.Context, blockerID, targetID primitive.ObjectID) (bool, error) {
+	result, err := s.collection(collectionBlock).DeleteOne(ctx, bson.M{"blocker": blockerID.Hex(), "blocked": targetID.Hex()})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (s *mongoStore) ListBlocks(ctx context.Context, userID string) ([]Block, error) {
+	return findAll[Block](ctx, s.collection(collectionBlock), bson.M{"$or": bson.A{
+		bson.M{"blocker": userID},
+		bson.M{"blocked": userID},
+	}})
+}
+
+// --- Posts ---
+
+func (s *mongoStore) CreatePost(ctx context.Context, post Post) error {
+	_, err := s.collection(collectionPost).InsertOne(ctx, post)
+	return err
+}
+
+func (s *mongoStore) ListPosts(ctx context.Context, page Page) ([]Post, error) {
+	return findPage[Post](ctx, s.collection(collectionPost), bson.M{}, page)
+}
+
+func (s *mongoStore) GetPost(ctx context.Context, id primitive.ObjectID) (Post, error) {
+	return findOne[Post](ctx, s.collection(collectionPost), bson.M{"_id": id})
+}
+
+func (s *mongoStore) ListTimeline(ctx context.Context, authors []string, page Page) ([]Post, error) {
+	return findPage[Post](ctx, s.collection(collectionPost), bson.M{"author": bson.M{"$in": authors}}, page)
+}
+
+func (s *mongoStore) ListMentions(ctx context.Context, userID string, page Page) ([]Post, error) {
+	return findPage[Post](ctx, s.collection(collectionPost), bson.M{"$or": bson.A{
+		bson.M{"mentions.user": userID},
+		bson.M{"comments.mentions.user": userID},
+	}}, page)
+}
+
+func (s *mongoStore) ListPostsByHashtag(ctx context.Context, tag string, page Page) ([]Post, error) {
+	return findPage[Post](ctx, s.collection(collectionPost), bson.M{"hashtags": tag}, page)
+}
+
+// Вес поста 2^(-возраст/halfLife) считается в агрегации
+func (s *mongoStore) TrendingHashtags(ctx context.Context, since, now time.Time, halfLife time.Duration, limit int) ([]Trend, error) {
+	weight := bson.M{"$exp": bson.M{"$divide": bson.A{
+		bson.M{"$subtract": bson.A{"$createDate", now}},
+		float64(halfLife.Milliseconds()) / math.Ln2,
+	}}}
+	cursor, err := s.collection(collectionPost).Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"createDate": bson.M{"$gte": since, "$lt": now}, "hashtags.0": bson.M{"$exists": true}}}},
+		{{Key: "$unwind", Value: "$hashtags"}},
+		{{Key: "$group", Value: bson.M{"_id": "$hashtags", "posts": bson.M{"$sum": 1}, "score": bson.M{"$sum": weight}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "tag": "$_id", "posts": 1, "score": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var trends []Trend
+	if err := cursor.All(ctx, &trends); err != nil {
+		return nil, err
+	}
+	return trends, nil
+}
+
+func (s *mongoStore) ListPostsByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Post, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return findAll[Post](ctx, s.collection(collectionPost), bson.M{"_id": bson.M{"$in": ids}})
+}
+
+func (s *mongoStore) UpdatePost(ctx context.Context, id primitive.ObjectID, updates Post, fields []string) (Post, error) {
+	return setFields(ctx, s.collection(collectionPost), id, updates, fields)
+}
+
+func (s *mongoStore) DeletePost(ctx context.Context, id primitive.ObjectID) error {
+	if err := deleteByID(ctx, s.collection(collectionPost), id); err != nil {
+		return err
+	}
+	if _, err := s.collection(collectionPost).DeleteMany(ctx, bson.M{"repostOf": id.Hex()}); err != nil {
+		return err
+	}
+	if _, err := s.collection(collectionLike).DeleteMany(ctx, bson.M{"post": id.Hex()}); err != nil {
+		return err
+	}
+	_, err := s.collection(collectionBookmark).DeleteMany(ctx, bson.M{"post": id.Hex()})
+	return err
+}
+
+// Имя счётчика у оригинала для репоста или цитаты
+func repostCounter(post Post) string {
+	if post.RepostOf != "" {
+		return "repostCount"
+	}
+	return "quoteCount"
+}
+
+func (s *mongoStore) CreateRepost(ctx context.Context, post Post) (bool, error) {
+	originalID, err := primitive.ObjectIDFromHex(post.OriginalID())
+	if err != nil {
+		return false, errNotFound
+	}
+
+	posts := s.collection(collectionPost)
+	created := false
+	err = s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		created = false
+		if post.RepostOf != "" {
+			// Второй репост того же поста тем же автором не создаётся
+			result, err := posts.UpdateOne(sc,
+				bson.M{"author": post.Author, "repostOf": post.RepostOf},
+				bson.M{"$setOnInsert": post},
+				options.Update().SetUpsert(true),
+			)
+			if err != nil || result.UpsertedCount == 0 {
+				return err
+			}
+		} else if _, err := posts.InsertOne(sc, post); err != nil {
+			return err
+		}
+
+		result, err := posts.UpdateOne(sc,
+			bson.M{"_id": originalID},
+			bson.M{"$inc": bson.M{repostCounter(post): 1}},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errNotFound
+		}
+		created = true
+		return nil
+	})
+	return created, err
+}
+
+func (s *mongoStore) DeleteRepost(ctx context.Context, post Post) error {
+	originalID, _ := primitive.ObjectIDFromHex(post.OriginalID())
+	posts := s.collection(collectionPost)
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		result, err := posts.DeleteOne(sc, bson.M{"_id": post.ID})
+		if err != nil {
+			return err
+		}
+		if result.DeletedCount == 0 {
+			return errNotFound
+		}
+
+		counter := repostCounter(post)
+		_, err = posts.UpdateOne(sc,
+			bson.M{"_id": originalID, counter: bson.M{"$gt": 0}},
+			bson.M{"$inc": bson.M{counter: -1}},
+		)
+		return err
+	})
+}
+
+func (s *mongoStore) GetRepost(ctx context.Context, author string, originalID primitive.ObjectID) (Post, error) {
+	return findOne[Post](ctx, s.collection(collectionPost), bson.M{"author": author, "repostOf": originalID.Hex()})
+}
+
+func (s *mongoStore) ListRepostsOf(ctx context.Context, postIDs []string) ([]Post, error) {
+	if len(postIDs) == 0 {
+		return nil, nil
+	}
+	return findAll[Post](ctx, s.collection(collectionPost), bson.M{"repostOf": bson.M{"$in": postIDs}})
+}
+
+func (s *mongoStore) ListRepostsBy(ctx context.Context, authors []string) ([]Post, error) {
+	return findAll[Post](ctx, s.collection(collectionPost), bson.M{"author": bson.M{"$in": authors}, "repostOf": bson.M{"$exists": true}})
+}
+
+func (s *mongoStore) LikePost(ctx context.Context, user User, post Post) (bool, error) {
+	like := LikePost{ID: primitive.NewObjectID(), User: user.ID.Hex(), Post: post.ID.Hex(), Author: post.Author, State: true, CreateDate: timestampNow()}
+	liked := false
+	err := s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		liked = false
+		result, err := s.collection(collectionLike).UpdateOne(sc,
+			bson.M{"user": like.User, "post": like.Post},
+			bson.M{"$setOnInsert": like},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil || result.UpsertedCount == 0 {
+			return err
+		}
+
+		result, err = s.collection(collectionPost).UpdateOne(sc,
+			bson.M{"_id": post.ID},
+			bson.M{"$inc": bson.M{"likes": 1}},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errNotFound
+		}
+		liked = true
+		return nil
+	})
+	return liked, err
+}
+
+func (s *mongoStore) UnlikePost(ctx context.Context, userID, postID primitive.ObjectID) (bool, error) {
+	unliked := false
+	err := s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		unliked = false
+		result, err := s.collection(collectionLike).DeleteOne(sc, bson.M{"user": userID.Hex(), "post": postID.Hex()})
+		if err != nil || result.DeletedCount == 0 {
+			return err
+		}
+
+		_, err = s.collection(collectionPost).UpdateOne(sc,
+			bson.M{"_id": postID, "likes": bson.M{"$gt": 0}},
+			bson.M{"$inc": bson.M{"likes": -1}},
+		)
+		unliked = err == nil
+		return err
+	})
+	return unliked, err
+}
+
+func (s *mongoStore) ListLikes(ctx context.Context, postID primitive.ObjectID, page Page) ([]LikePost, error) {
+	return findPage[LikePost](ctx, s.collection(collectionLike), bson.M{"post": postID.Hex()}, page)
+}
+
+func (s *mongoStore) ListUserLikes(ctx context.Context, userIDs []string) ([]LikePost, error) {
+	return findAll[LikePost](ctx, s.collection(collectionLike), bson.M{"user": bson.M{"$in": userIDs}})
+}
+
+func (s *mongoStore) BookmarkPost(ctx context.Context, user User, post Post) (bool, error) {
+	bookmark := Bookmark{ID: primitive.NewObjectID(), User: user.ID.Hex(), Post: post.ID.Hex(), Author: post.Author, State: true, CreateDate: timestampNow()}
+	result, err := s.collection(collectionBookmark).UpdateOne(ctx,
+		bson.M{"user": bookmark.User, "post": bookmark.Post},
+		bson.M{"$setOnInsert": bookmark},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount > 0, nil
+}
+
+func (s *mongoStore) UnbookmarkPost(ctx context.Context, userID, postID primitive.ObjectID) (bool, error) {
+	result, err := s.collection(collectionBookmark).DeleteOne(ctx, bson.M{"user": userID.Hex(), "post": postID.Hex()})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (s *mongoStore) ListBookmarks(ctx context.Context, userID primitive.ObjectID, page Page) ([]Bookmark, error) {
+	return findPage[Bookmark](ctx, s.collection(collectionBookmark), bson.M{"user": userID.Hex()}, page)
+}
+
+func (s *mongoStore) AddComment(ctx context.Context, postID primitive.ObjectID, comment Comment) error {
+	result, err := s.collection(collectionPost).UpdateOne(ctx,
+		bson.M{"_id": postID},
+		bson.M{"$push": bson.M{"comments": comment}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+func (s *mongoStore) UpdateComment(ctx context.Context, postID primitive.ObjectID, comment Comment) error {
+	result, err := s.collection(collectionPost).UpdateOne(ctx,
+		bson.M{"_id": postID, "comments._id": comment.ID},
+		bson.M{"$set": bson.M{
+			"comments.$.text":     comment.Text,
+			"comments.$.mentions": comment.Mentions,
+			"comments.$.editDate": comment.EditDate,
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+func (s *mongoStore) DeleteComments(ctx context.Context, postID primitive.ObjectID, commentIDs []primitive.ObjectID) error {
+	result, err := s.collection(collectionPost).UpdateOne(ctx,
+		bson.M{"_id": postID},
+		bson.M{"$pull": bson.M{"comments": bson.M{"_id": bson.M{"$in": commentIDs}}}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+// --- Chats ---
+
+func (s *mongoStore) CreateChat(ctx context.Context, chat Chat) error {
+	_, err := s.collection(collectionChat).InsertOne(ctx, chat)
+	return err
+}
+
+func (s *mongoStore) ListChats(ctx context.Context, page Page) ([]Chat, error) {
+	return findPage[Chat](ctx, s.collection(collectionChat), bson.M{}, page)
+}
+
+func (s *mongoStore) ListChatsByIDD(ctx context.Context, idd string, page Page) ([]Chat, error) {
+	return findPage[Chat](ctx, s.collection(collectionChat), bson.M{"idd": idd}, page)
+}
+
+func (s *mongoStore) ListChatsByIDDs(ctx context.Context, idds []string, page Page) ([]Chat, error) {
+	return findPage[Chat](ctx, s.collection(collectionChat), bson.M{"idd": bson.M{"$in": idds}}, page)
+}
+
+func (s *mongoStore) ListChatIDDs(ctx context.Context, author string) ([]string, error) {
+	values, err := s.collection(collectionChat).Distinct(ctx, "idd", bson.M{"author": author})
+	if err != nil {
+		return nil, err
+	}
+	idds := make([]string, 0, len(values))
+	for _, v := range values {
+		if idd, ok := v.(string); ok {
+			idds = append(idds, idd)
+		}
+	}
+	return idds, nil
+}
+
+// --- Messages ---
+
+func (s *mongoStore) CreateMessage(ctx context.Context, message Message) error {
+	_, err := s.collection(collectionMessage).InsertOne(ctx, message)
+	return err
+}
+
+func (s *mongoStore) ListMessages(ctx context.Context, page Page) ([]Message, error) {
+	return findPage[Message](ctx, s.collection(collectionMessage), bson.M{}, page)
+}
+
+func (s *mongoStore) ListUserMessages(ctx context.Context, userID string, page Page) ([]Message, error) {
+	filter := bson.M{"$or": bson.A{bson.M{"sender": userID}, bson.M{"receiver": userID}}}
+	return findPage[Message](ctx, s.collection(collectionMessage), filter, page)
+}
+
+func (s *mongoStore) GetMessage(ctx context.Context, id primitive.ObjectID) (Message, error) {
+	return findOne[Message](ctx, s.collection(collectionMessage), bson.M{"_id": id})
+}
+
+func (s *mongoStore) GetMessageByIDField(ctx context.Context, id string) (Message, error) {
+	return findOne[Message](ctx, s.collection(collectionMessage), bson.M{"id": id})
+}
+
+func (s *mongoStore) UpdateMessage(ctx context.Context, id primitive.ObjectID, updates Message, fields []string) (Message, error) {
+	return setFields(ctx, s.collection(collectionMessage), id, updates, fields)
+}
+
+func (s *mongoStore) DeleteMessage(ctx context.Context, id primitive.ObjectID) error {
+	return deleteByID(ctx, s.collection(collectionMessage), id)
+}
+
+// --- Notices ---
+
+func (s *mongoStore) CreateNotice(ctx context.Context, notice Notice) error {
+	_, err := s.collection(collectionNotice).InsertOne(ctx, notice)
+	return err
+}
+
+func (s *mongoStore) CreateNoticeOnce(ctx context.Context, notice Notice) (bool, error) {
+	filter := bson.M{"user": notice.User, "type": notice.Type, "post": notice.Post, "comment": notice.Comment}
+	if notice.Comment == "" {
+		filter["comment"] = bson.M{"$exists": false}
+	}
+	result, err := s.collection(collectionNotice).UpdateOne(ctx, filter,
+		bson.M{"$setOnInsert": notice},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount > 0, nil
+}
+
+func (s *mongoStore) ListNotices(ctx context.Context, page Page) ([]Notice, error) {
+	return findPage[Notice](ctx, s.collection(collectionNotice), bson.M{}, page)
+}
+
+func (s *mongoStore) GetNotice(ctx context.Context, id primitive.ObjectID) (Notice, error) {
+	return findOne[Notice](ctx, s.collection(collectionNotice), bson.M{"_id": id})
+}
+
+func (s *mongoStore) UpdateNotice(ctx context.Context, id primitive.ObjectID, updates Notice, fields []string) (Notice, error) {
+	return setFields(ctx, s.collection(collectionNotice), id, updates, fields)
+}
+
+func (s *mongoStore) DeleteNotice(ctx context.Context, id primitive.ObjectID) error {
+	return deleteByID(ctx, s.collection(collectionNotice), id)
+}
+
+// --- Sessions ---
+
+func (s *mongoStore) CreateSession(ctx context.Context, session Session) error {
+	_, err := s.collection(collectionSession).InsertOne(ctx, session)
+	return err
+}
+
+func (s *mongoStore) GetActiveSession(ctx context.Context, id primitive.ObjectID) (Session, error) {
+	return findOne[Session](ctx, s.collection(collectionSession), bson.M{
+		"_id":       id,
+		"revoked":   false,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	})
+}
+
+func (s *mongoStore) RotateSession(ctx context.Context, id primitive.ObjectID, oldHash, newHash string) (Session, error) {
+	var session Session
+	err := s.collection(collectionSession).FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "refreshHash": oldHash, "revoked": false, "expiresAt": bson.M{"$gt": time.Now()}},
+		bson.M{"$set": bson.M{"refreshHash": newHash}, "$push": bson.M{"previousHashes": oldHash}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&session)
+	return session, mongoErr(err)
+}
+
+func (s *mongoStore) RevokeReusedSession(ctx context.Context, id primitive.ObjectID, hash string) (bool, error) {
+	result, err := s.collection(collectionSession).UpdateOne(ctx,
+		bson.M{"_id": id, "previousHashes": hash},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (s *mongoStore) RevokeSession(ctx context.Context, id primitive.ObjectID) error {
+	_, err := s.collection(collectionSession).UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"revoked": true}})
+	return err
+}
+
+func (s *mongoStore) RevokeUserSessions(ctx context.Context, userID string) error {
+	_, err := s.collection(collectionSession).UpdateMany(ctx, bson.M{"user": userID, "revoked": false}, bson.M{"$set": bson.M{"revoked": true}})
+	return err
+}
+
+// --- Search ---
+
+// Строка $search: слова через пробел, фразы в кавычках
+func textSearch(query SearchQuery) string {
+	parts := append([]string{}, query.Terms...)
+	for _, phrase := range query.Phrases {
+		parts = append(parts, `"`+strings.ReplaceAll(phrase, `"`, "")+`"`)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (s *mongoStore) SearchPosts(ctx context.Context, query SearchQuery, page Page) ([]Post, error) {
+	filter := bson.M{"$text": bson.M{"$search": textSearch(query)}}
+	if query.Author != "" {
+		filter["author"] = query.Author
+	}
+	date := bson.M{}
+	if !query.Since.IsZero() {
+		date["$gte"] = query.Since
+	}
+	if !query.Until.IsZero() {
+		date["$lt"] = query.Until
+	}
+	if len(date) > 0 {
+		filter["createDate"] = date
+	}
+	return searchPage[Post](ctx, s.collection(collectionPost), filter, query.Sort, page,
+		func(p Post) primitive.ObjectID { return p.ID })
+}
+
+func (s *mongoStore) SearchUsers(ctx context.Context, query SearchQuery, page Page) ([]User, error) {
+	filter := bson.M{"$text": bson.M{"$search": textSearch(query)}}
+	return searchPage[User](ctx, s.collection(collectionUser), filter, query.Sort, page,
+		func(u User) primitive.ObjectID { return u.ID })
+}
+
+// Страница результатов $text-запроса. По новизне — обычная страница по _id.
+// По релевантности сначала ранжируются только _id (не больше maxSearchResults),
+// затем загружаются документы страницы.
+func searchPage[T any](ctx context.Context, coll *mongo.Collection, filter bson.M, sort string, page Page, idOf func(T) primitive.ObjectID) ([]T, error) {
+	if sort == searchSortRecent {
+		return findPage[T](ctx, coll, filter, page)
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	ranked, err := findAll[struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}](ctx, coll, filter, options.Find().
+		SetProjection(bson.M{"_id": 1, "score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: -1}}).
+		SetLimit(maxSearchResults))
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []primitive.ObjectID
+	for _, r := range ranked {
+		ids = append(ids, r.ID)
+	}
+	ids = pageAfter(ids, page, func(id primitive.ObjectID) primitive.ObjectID { return id })
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	docs, err := findAll[T](ctx, coll, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[primitive.ObjectID]T, len(docs))
+	for _, doc := range docs {
+		byID[idOf(doc)] = doc
+	}
+	items := make([]T, 0, len(ids))
+	for _, id := range ids {
+		if doc, ok := byID[id]; ok {
+			items = append(items, doc)
+		}
+	}
+	return items, nil
+}
+
+var _ Store = (*mongoStore)(nil)
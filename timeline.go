@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Элемент домашней ленты. Для репоста в Post лежит оригинал,
+// а в RepostedBy — подписка, сделавшая репост.
+type TimelineItem struct {
+	Post       Post   `json:"post"`
+	RepostedBy string `json:"repostedBy,omitempty"`
+
+	cursor primitive.ObjectID
+}
+
+// Домашняя лента строится при чтении (fan-out-on-read): один запрос
+// к posts по списку авторов, репосты — тоже их посты. При нашем числе
+// подписок на пользователя это дешевле, чем раскладывать каждый пост
+// по лентам подписчиков.
+//
+// Подписки на удалённые аккаунты пропускаются: их посты и репосты
+// из ленты пропадают, хотя сами документы постов остаются в базе.
+func (s *server) getTimeline(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	if !authorize(w, r, id.Hex()) {
+		return
+	}
+
+	page, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := s.users.GetUser(ctx, id)
+	if err != nil {
+		storeError(w, err, "User not found")
+		return
+	}
+
+	authors, err := s.timelineAuthors(ctx, user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	posts, err := s.posts.ListTimeline(ctx, authors, page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.hydratePosts(ctx, posts); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]TimelineItem, 0, len(posts))
+	for _, post := range posts {
+		item := TimelineItem{Post: post, cursor: post.ID}
+		if post.Original != nil && post.RepostOf != "" {
+			// Репост показывается как оригинал
+			item.Post = *post.Original
+			item.RepostedBy = post.Author
+		}
+		items = append(items, item)
+	}
+
+	// Курсор — id документа ленты (для репоста это сам репост, а не оригинал)
+	writePage(w, items, page, func(item TimelineItem) primitive.ObjectID { return item.cursor })
+}
+
+// Сам пользователь плюс существующие аккаунты из его подписок
+func (s *server) timelineAuthors(ctx context.Context, user User) ([]string, error) {
+	following, err := s.users.ListFollowing(ctx, []string{user.ID.Hex()})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(following))
+	for _, f := range following {
+		if id, err := primitive.ObjectIDFromHex(f.Followee); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	followed, err := s.users.ListUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	authors := []string{user.ID.Hex()}
+	for _, u := range followed {
+		if u.ID != user.ID {
+			authors = append(authors, u.ID.Hex())
+		}
+	}
+	return authors, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// Timestamp — время в моделях. В базе хранится как BSON date,
+// в JSON отдаётся как RFC 3339.
+//
+// Старые документы (времён Mongoose) хранили даты строками, а клиенты
+// присылали их в запросах. Такие значения читаются без ошибок: строка
+// разбирается в одном из известных форматов, а неразобранная даёт
+// нулевое время. Модели из stringDateFields заменяют его временем из _id,
+// как и миграция convert_string_dates. Даты в моделях всегда выставляет сервер.
+type Timestamp struct {
+	time.Time
+}
+
+// Время с точностью до миллисекунд, как оно сохранится в MongoDB
+func newTimestamp(t time.Time) Timestamp {
+	return Timestamp{t.UTC().Truncate(time.Millisecond)}
+}
+
+func timestampNow() Timestamp {
+	return newTimestamp(time.Now())
+}
+
+// Форматы строковых дат, встречающиеся в старых документах. Общие для
+// чтения и миграции convert_string_dates.
+var legacyTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"Mon Jan 02 2006 15:04:05 GMT-0700",
+}
+
+func parseLegacyTime(s string) (time.Time, bool) {
+	// Date.toString() в JS добавляет " (название зоны)"
+	if i := strings.Index(s, " ("); i > 0 {
+		s = s[:i]
+	}
+	for _, layout := range legacyTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Нулевое (отсутствующее или неразобранное) время заменяется временем
+// создания документа id
+func (t Timestamp) orCreatedAt(id primitive.ObjectID) Timestamp {
+	if t.IsZero() && !id.IsZero() {
+		return newTimestamp(id.Timestamp())
+	}
+	return t
+}
+
+// Даты моделей из stringDateFields после чтения из базы
+
+func (u *User) UnmarshalBSON(data []byte) error {
+	type plain User
+	if err := bson.Unmarshal(data, (*plain)(u)); err != nil {
+		return err
+	}
+	u.RegistrationDate = u.RegistrationDate.orCreatedAt(u.ID)
+	return nil
+}
+
+func (p *Post) UnmarshalBSON(data []byte) error {
+	type plain Post
+	if err := bson.Unmarshal(data, (*plain)(p)); err != nil {
+		return err
+	}
+	p.CreateDate = p.CreateDate.orCreatedAt(p.ID)
+	return nil
+}
+
+func (c *Chat) UnmarshalBSON(data []byte) error {
+	type plain Chat
+	if err := bson.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	c.CreateDate = c.CreateDate.orCreatedAt(c.ID)
+	return nil
+}
+
+func (m *Message) UnmarshalBSON(data []byte) error {
+	type plain Message
+	if err := bson.Unmarshal(data, (*plain)(m)); err != nil {
+		return err
+	}
+	m.CreateDate = m.CreateDate.orCreatedAt(m.ID)
+	return nil
+}
+
+func (t Timestamp) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	if t.IsZero() {
+		return bsontype.Null, nil, nil
+	}
+	return bsontype.DateTime, bsoncore.AppendDateTime(nil, t.UnixMilli()), nil
+}
+
+func (t *Timestamp) UnmarshalBSONValue(typ bsontype.Type, data []byte) error {
+	value := bsoncore.Value{Type: typ, Data: data}
+	switch typ {
+	case bsontype.DateTime:
+		*t = Timestamp{time.UnixMilli(value.DateTime()).UTC()}
+	case bsontype.String:
+		parsed, _ := parseLegacyTime(value.StringValue())
+		*t = newTimestamp(parsed)
+	case bsontype.Int64:
+		*t = Timestamp{time.UnixMilli(value.Int64()).UTC()}
+	case bsontype.Null, bsontype.Undefined:
+		*t = Timestamp{}
+	default:
+		return fmt.Errorf("cannot decode %s into Timestamp", typ)
+	}
+	return nil
+}
+
+// Значения от клиента игнорируются, поэтому некорректная дата
+// не должна превращать весь запрос в 400
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		*t = Timestamp{}
+		return nil
+	}
+	parsed, _ := parseLegacyTime(s)
+	*t = newTimestamp(parsed)
+	return nil
+}
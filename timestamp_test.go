@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestLegacyDatesFallBackToObjectID(t *testing.T) {
+	created := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	id := primitive.NewObjectIDFromTimestamp(created)
+	stored := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  time.Time
+	}{
+		{"date", stored, stored},
+		{"RFC 3339 string", "2022-01-02T03:04:05Z", stored},
+		{"JS Date string", "Sun Jan 02 2022 03:04:05 GMT+0000 (Coordinated Universal Time)", stored},
+		{"unparsed string", "yesterday", created},
+		{"empty string", "", created},
+		{"null", nil, created},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := bson.Marshal(bson.M{"_id": id, "text": "hi", "createDate": tt.value})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var post Post
+			if err := bson.Unmarshal(data, &post); err != nil {
+				t.Fatal(err)
+			}
+			if !post.CreateDate.Equal(tt.want) || post.Text != "hi" {
+				t.Fatalf("createDate = %v, want %v", post.CreateDate, tt.want)
+			}
+
+			// Сохранённый обратно документ получает дату, а не null
+			out, err := bson.Marshal(post)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if typ := bson.Raw(out).Lookup("createDate").Type; typ != bson.TypeDateTime {
+				t.Errorf("re-encoded createDate type = %v, want date", typ)
+			}
+		})
+	}
+
+	data, _ := bson.Marshal(bson.M{"_id": id, "name": "alice"})
+	var user User
+	if err := bson.Unmarshal(data, &user); err != nil {
+		t.Fatal(err)
+	}
+	if !user.RegistrationDate.Equal(created) {
+		t.Errorf("missing registrationDate = %v, want %v", user.RegistrationDate, created)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCreateUserConflict(t *testing.T) {
+	api := newTestAPI(t)
+	alice, token := api.register("alice")
+
+	// Тот же googleId
+	api.expect(http.StatusConflict, "POST", "/api/twitter/users", token, map[string]string{"name": "again"})
+
+	// Тот же email у другого Google-аккаунта
+	claims := googleTestClaims("alice-2")
+	claims["email"] = alice.Email
+	other := signTestToken(t, api.key, "test-key", claims)
+	api.expect(http.StatusConflict, "POST", "/api/twitter/users", other, map[string]string{"name": "copy"})
+	api.expect(http.StatusConflict, "POST", "/api/twitter/auth/google", other, nil)
+
+	if n := len(api.store.users); n != 1 {
+		t.Errorf("%d users stored, want 1", n)
+	}
+}
+
+// Ответ /auth/google с представлением самого пользователя
+type googleAuthResult struct {
+	tokenPair
+	User    SelfUser `json:"user"`
+	Created bool     `json:"created"`
+}
+
+func TestGoogleAuthLoginOrRegister(t *testing.T) {
+	api := newTestAPI(t)
+	token := api.token("alice")
+
+	var first googleAuthResult
+	decodeJSON(t, api.expect(http.StatusCreated, "POST", "/api/twitter/auth/google", token, map[string]string{"name": "Alice"}), &first)
+	if !first.Created || first.User.Name != "Alice" || first.AccessToken == "" || first.RefreshToken == "" {
+		t.Fatalf("first login = %+v", first)
+	}
+
+	// Второй вход находит того же пользователя; тело при входе не используется
+	var second googleAuthResult
+	decodeJSON(t, api.expect(http.StatusOK, "POST", "/api/twitter/auth/google", token, map[string]string{"name": "Renamed"}), &second)
+	if second.Created || second.User.ID != first.User.ID || second.User.Name != "Alice" {
+		t.Errorf("second login = %+v", second)
+	}
+	if n := len(api.store.users); n != 1 {
+		t.Errorf("%d users stored, want 1", n)
+	}
+
+	// Без тела имя берётся из токена
+	var bob googleAuthResult
+	decodeJSON(t, api.expect(http.StatusCreated, "POST", "/api/twitter/auth/google", api.token("bob"), nil), &bob)
+	if bob.User.Name != "bob" {
+		t.Errorf("bob = %+v", bob.User)
+	}
+}
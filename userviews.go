@@ -0,0 +1,98 @@
+package main
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Представления User для ответов API. User целиком наружу не отдаётся:
+// какое представление получит вызывающий, решает viewUser.
+
+// Видно всем: без email, googleId, роли и сообщений; день рождения —
+// с учётом его видимости
+type PublicUser struct {
+	ID               primitive.ObjectID `json:"_id"`
+	Handle           string             `json:"handle,omitempty"`
+	Name             string             `json:"name"`
+	Avatar           string             `json:"avatar"`
+	RegistrationDate Timestamp          `json:"registrationDate"`
+	Profile          UserProfile        `json:"profile"`
+	Subscriptions    []Subscription     `json:"subscriptions"`
+	Subscribers      []Subscriber       `json:"subscribers"`
+	LikesPosts       []LikePost         `json:"likesPosts"`
+	Reposts          []Repost           `json:"reposts"`
+	Posts            []UserPost         `json:"posts"`
+}
+
+// Сам пользователь видит ещё свои учётные данные, сообщения и профиль целиком
+type SelfUser struct {
+	PublicUser
+	GoogleID string        `json:"googleId"`
+	Email    string        `json:"email"`
+	Profile  UserProfile   `json:"profile"`
+	Messages []UserMessage `json:"messages"`
+}
+
+// Администратор видит всё, включая роль
+type AdminUser struct {
+	SelfUser
+	Role string `json:"role"`
+}
+
+// Любое из представлений пользователя
+type userView interface {
+	userID() primitive.ObjectID
+}
+
+func (u PublicUser) userID() primitive.ObjectID { return u.ID }
+
+func publicUser(user User) PublicUser {
+	profile := user.Profile
+	profile.Birthday = visibleBirthday(profile)
+	return PublicUser{
+		ID:               user.ID,
+		Handle:           user.Handle,
+		Name:             user.Name,
+		Avatar:           user.Avatar,
+		RegistrationDate: user.RegistrationDate,
+		Profile:          profile,
+		Subscriptions:    user.Subscriptions,
+		Subscribers:      user.Subscribers,
+		LikesPosts:       user.LikesPosts,
+		Reposts:          user.Reposts,
+		Posts:            user.Posts,
+	}
+}
+
+func selfUser(user User) SelfUser {
+	return SelfUser{
+		PublicUser: publicUser(user),
+		GoogleID:   user.GoogleID,
+		Email:      user.Email,
+		Profile:    user.Profile,
+		Messages:   user.Messages,
+	}
+}
+
+func adminUser(user User) AdminUser {
+	return AdminUser{SelfUser: selfUser(user), Role: user.Role}
+}
+
+// Представление user для вызывающего caller (nil — аноним)
+func viewUser(caller *User, user User) userView {
+	switch {
+	case isAdmin(caller):
+		return adminUser(user)
+	case caller != nil && caller.ID == user.ID:
+		return selfUser(user)
+	default:
+		return publicUser(user)
+	}
+}
+
+func viewUsers(caller *User, users []User) []userView {
+	views := make([]userView, len(users))
+	for i, u := range users {
+		views[i] = viewUser(caller, u)
+	}
+	return views
+}
+
+func viewID(v userView) primitive.ObjectID { return v.userID() }
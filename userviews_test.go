@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sort"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	publicUserKeys = []string{
+		"_id", "avatar", "handle", "likesPosts", "name", "posts", "profile",
+		"registrationDate", "reposts", "subscribers", "subscriptions",
+	}
+	selfUserKeys  = append(slices.Clone(publicUserKeys), "email", "googleId", "messages")
+	adminUserKeys = append(slices.Clone(selfUserKeys), "role")
+
+	// Не должны попадать в ответ чужому или анониму
+	privateUserKeys = []string{"email", "googleId", "messages", "bookmarks", "role"}
+)
+
+func jsonKeys(t *testing.T, data []byte) []string {
+	t.Helper()
+	var m map[string]json.RawMessage
+	decodeJSON(t, data, &m)
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sorted(keys []string) []string {
+	keys = slices.Clone(keys)
+	sort.Strings(keys)
+	return keys
+}
+
+func TestViewUserFields(t *testing.T) {
+	user := User{
+		ID:       primitive.NewObjectID(),
+		GoogleID: "g-1",
+		Name:     "alice",
+		Email:    "alice@example.com",
+		Handle:   "alice",
+		Role:     "user",
+		Profile:  UserProfile{Birthday: "1990-05-06", BirthdayVisibility: birthdayMonthDay},
+		Messages: []UserMessage{{Author: "bob", MessagesID: "chat"}},
+	}
+	other := &User{ID: primitive.NewObjectID()}
+	admin := &User{ID: primitive.NewObjectID(), Role: roleAdmin}
+
+	tests := []struct {
+		name   string
+		caller *User
+		keys   []string
+	}{
+		{"anonymous", nil, publicUserKeys},
+		{"other user", other, publicUserKeys},
+		{"self", &user, selfUserKeys},
+		{"admin", admin, adminUserKeys},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(viewUser(tt.caller, user))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := jsonKeys(t, data), sorted(tt.keys); !slices.Equal(got, want) {
+				t.Errorf("keys = %v, want %v", got, want)
+			}
+		})
+	}
+
+	// Чужим день рождения виден только в пределах выбранной видимости
+	if got := publicUser(user).Profile.Birthday; got != "05-06" {
+		t.Errorf("public birthday = %q, want 05-06", got)
+	}
+	if got := selfUser(user).Profile.Birthday; got != "1990-05-06" {
+		t.Errorf("self birthday = %q, want 1990-05-06", got)
+	}
+}
+
+func TestAnonymousNeverSeesPrivateFields(t *testing.T) {
+	api := newTestAPI(t)
+	alice, token := api.register("alice")
+	api.expect(http.StatusOK, "PUT", "/api/twitter/users/"+alice.ID.Hex()+"/handle", token, map[string]string{"handle": "alice"})
+
+	check := func(t *testing.T, data []byte) {
+		t.Helper()
+		var m map[string]json.RawMessage
+		decodeJSON(t, data, &m)
+		for _, key := range privateUserKeys {
+			if _, ok := m[key]; ok {
+				t.Errorf("anonymous response contains %q: %s", key, data)
+			}
+		}
+	}
+
+	t.Run("by googleId", func(t *testing.T) {
+		check(t, api.expect(http.StatusOK, "GET", "/api/twitter/users/alice", "", nil))
+	})
+	t.Run("by handle", func(t *testing.T) {
+		check(t, api.expect(http.StatusOK, "GET", "/api/twitter/users/by-handle/alice", "", nil))
+	})
+	t.Run("list", func(t *testing.T) {
+		var page pageResponse[json.RawMessage]
+		decodeJSON(t, api.expect(http.StatusOK, "GET", "/api/twitter/users", "", nil), &page)
+		if len(page.Data) == 0 {
+			t.Fatal("empty user list")
+		}
+		for _, item := range page.Data {
+			check(t, item)
+		}
+	})
+	t.Run("search", func(t *testing.T) {
+		var page pageResponse[json.RawMessage]
+		decodeJSON(t, api.expect(http.StatusOK, "GET", "/api/twitter/search?type=users&q=alice", "", nil), &page)
+		if len(page.Data) == 0 {
+			t.Fatal("empty search result")
+		}
+		for _, item := range page.Data {
+			check(t, item)
+		}
+	})
+}